@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"pm/internal/cache"
+	"pm/internal/logger"
+	"pm/internal/settings"
+)
+
+func openCache(log logger.LoggerInterface, s *settings.Settings) (*cache.Cache, error) {
+	dir := s.String("cache.dir")
+	if dir == "" {
+		dir = cache.DefaultDir()
+	}
+
+	c, err := cache.Open(dir, s.Size("cache.max_size"))
+	if err != nil {
+		log.Error("Ошибка открытия кэша", "путь", dir, "ошибка", err.Error())
+		return nil, err
+	}
+	return c, nil
+}
+
+// evictCache освобождает место в кэше после установки. Ошибка не прерывает
+// команду: архивы уже распакованы.
+func evictCache(log logger.LoggerInterface, c *cache.Cache) {
+	evicted, err := c.Evict()
+	if err != nil {
+		log.Warn("Ошибка очистки кэша", "путь", c.Dir(), "ошибка", err.Error())
+	}
+	for _, e := range evicted {
+		log.Debug("Архив удалён из кэша", "файл", e.File, "sha256", e.Checksum)
+	}
+}
+
+func handleCacheList(s *settings.Settings, log logger.LoggerInterface) error {
+	c, err := openCache(log, s)
+	if err != nil {
+		return err
+	}
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ПАКЕТ\tВЕРСИЯ\tРАЗМЕР\tИСПОЛЬЗОВАН\tSHA256")
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		total += e.Size
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Name, e.Version, humanSize(e.Size), e.Used.Format("2006-01-02 15:04"), e.Checksum[:12])
+	}
+	w.Flush()
+
+	log.Info("Кэш", "путь", c.Dir(), "архивов", len(entries), "размер", humanSize(total))
+	return nil
+}
+
+func handleCacheClean(s *settings.Settings, log logger.LoggerInterface) error {
+	c, err := openCache(log, s)
+	if err != nil {
+		return err
+	}
+
+	removed, err := c.Clean()
+	if err != nil {
+		log.Error("Ошибка очистки кэша", "путь", c.Dir(), "ошибка", err.Error())
+		return err
+	}
+
+	var freed int64
+	for _, e := range removed {
+		freed += e.Size
+	}
+	log.Info("Кэш очищен", "путь", c.Dir(), "удалено_архивов", len(removed), "освобождено", humanSize(freed))
+	return nil
+}
+
+func handleCacheVerify(s *settings.Settings, log logger.LoggerInterface) error {
+	c, err := openCache(log, s)
+	if err != nil {
+		return err
+	}
+
+	corrupted, err := c.Verify()
+	for _, e := range corrupted {
+		log.Warn("Повреждённый архив удалён из кэша", "файл", e.File, "sha256", e.Checksum)
+	}
+	if err != nil {
+		log.Error("Ошибка проверки кэша", "путь", c.Dir(), "ошибка", err.Error())
+		return err
+	}
+
+	log.Info("Проверка кэша завершена", "путь", c.Dir(), "повреждено", len(corrupted))
+	return nil
+}
+
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"pm/internal/cli"
+	"pm/internal/logger"
+	"pm/internal/settings"
+)
+
+func handleConfigGet(key string, showOrigin bool, s *settings.Settings, log logger.LoggerInterface) error {
+	if err := settings.Validate(key, ""); err != nil {
+		return err
+	}
+
+	v, ok := s.Get(key)
+	if !ok {
+		log.Debug("Параметр не задан", "параметр", key)
+		return fmt.Errorf("параметр %s не задан", key)
+	}
+
+	if showOrigin {
+		fmt.Printf("%s\t(%s)\n", v.Value, v.Source)
+	} else {
+		fmt.Println(v.Value)
+	}
+	return nil
+}
+
+func handleConfigSet(key, value string, scope cli.ConfigScope, log logger.LoggerInterface) error {
+	var path string
+	switch scope {
+	case cli.ScopeProject:
+		path = settings.ProjectPath()
+	case cli.ScopeSystem:
+		path = settings.SystemPath()
+	default:
+		path = settings.UserPath()
+	}
+	if path == "" {
+		return fmt.Errorf("не удалось определить путь к файлу настроек")
+	}
+
+	if err := settings.Set(path, key, value); err != nil {
+		log.Error("Ошибка записи настроек", "файл", path, "ошибка", err.Error())
+		return err
+	}
+
+	log.Info("Параметр сохранён", "параметр", key, "значение", value, "файл", path)
+	return nil
+}
+
+// handleConfigList выводит итоговые значения всех заданных параметров вместе
+// с их источником: файлом, профилем, переменной окружения или флагом.
+func handleConfigList(s *settings.Settings) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, v := range s.Values() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.Key, v.Value, v.Source)
+	}
+	w.Flush()
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"pm/config"
+	"pm/internal/archive"
+	"pm/internal/errors"
+	"pm/internal/logger"
+	"pm/internal/repository"
+	"pm/internal/settings"
+	"pm/internal/signing"
+	"pm/internal/utils"
+	"pm/pkg/version"
+)
+
+func handleCreate(configPath string, s *settings.Settings, log logger.LoggerInterface) error {
+	packet, err := config.LoadPacketConfig(configPath)
+	if err != nil {
+		log.Error("Ошибка загрузки конфигурации", "путь", configPath, "ошибка", err.Error())
+		return err
+	}
+
+	root := packet.Root
+	if root == "" {
+		root = "."
+	}
+
+	ignoreDir := filepath.Dir(configPath)
+	ignorePath := filepath.Join(ignoreDir, archive.IgnoreFile)
+	ignore, err := archive.LoadIgnoreFile(ignorePath)
+	if err != nil {
+		log.Error("Ошибка чтения файла исключений", "путь", ignorePath, "ошибка", err.Error())
+		return err
+	}
+	if len(ignore) > 0 {
+		log.Debug("Загружены исключения", "путь", ignorePath, "шаблонов", len(ignore))
+	}
+
+	files, layout, err := archive.CollectLayout(log, packet.Targets, archive.CollectOptions{Root: root, Ignore: ignore, IgnoreDir: ignoreDir})
+	if err != nil {
+		log.Error("Ошибка сбора файлов", "ошибка", err.Error())
+		return err
+	}
+
+	archiveFormat := "zip"
+	if packet.Format != "" {
+		archiveFormat = packet.Format
+		log.Debug("Используется указанный формат архива", "формат", archiveFormat)
+	} else {
+		log.Debug("Используется формат архива по умолчанию", "формат", archiveFormat)
+	}
+
+	extension := getArchiveExtension(archiveFormat)
+	archiveName := packet.Name + "-" + packet.Ver + extension
+
+	// описание пакета в архиве позволяет восстановить зависимости при
+	// перестроении индекса
+	manifest, err := json.MarshalIndent(packet, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	log.Info("Создание архива", "имя", archiveName, "формат", archiveFormat, "корень", root, "файлов", len(files))
+
+	switch archiveFormat {
+	case "zip":
+		if err := archive.CreateZipWithManifest(log, files, archiveName, root, layout, manifest); err != nil {
+			log.Error("Ошибка создания ZIP архива", "имя", archiveName, "ошибка", err.Error())
+			return err
+		}
+	case "tar.gz", "tgz":
+		if err := archive.CreateTarGzWithManifest(log, files, archiveName, root, layout, manifest); err != nil {
+			log.Error("Ошибка создания tar.gz архива", "имя", archiveName, "ошибка", err.Error())
+			return err
+		}
+	default:
+		log.Error("Неподдерживаемый формат архива", "формат", archiveFormat)
+		return errors.NewArchiveCreationError(archiveName, files, fmt.Errorf("неподдерживаемый формат архива: %s", archiveFormat))
+	}
+
+	log.Info("Архив успешно создан", "имя", archiveName, "формат", archiveFormat)
+
+	repoURL, err := repositoryURL(log, s)
+	if err != nil {
+		return err
+	}
+	if repoURL == "" {
+		log.Info("Репозиторий не настроен. Архив сохранён локально", "путь", archiveName)
+		return nil
+	}
+
+	repo, err := openRepository(log, s, repoURL)
+	if stderrors.Is(err, errors.ErrInvalidSSHConfig) {
+		log.Info("SSH не настроен. Архив сохранён локально", "путь", archiveName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	entry, err := newIndexEntry(packet, archiveFormat, archiveName)
+	if err != nil {
+		log.Error("Ошибка вычисления контрольной суммы", "файл", archiveName, "ошибка", err.Error())
+		return err
+	}
+	log.Debug("Контрольная сумма архива", "файл", archiveName, "sha256", entry.Checksum)
+
+	signature, err := signArchive(log, s, archiveName, entry.Checksum)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("Загрузка архива в репозиторий", "локальный_файл", archiveName, "репозиторий", repo.URL())
+	if err := repo.Upload(archiveName, archiveName); err != nil {
+		log.Error("Ошибка загрузки архива в репозиторий", "файл", archiveName, "ошибка", err.Error())
+		return err
+	}
+
+	checksumFile := archiveName + utils.ChecksumExtension
+	if err := repo.UploadReader(strings.NewReader(utils.FormatChecksumFile(entry.Checksum, archiveName)), checksumFile); err != nil {
+		log.Error("Ошибка загрузки контрольной суммы", "файл", checksumFile, "ошибка", err.Error())
+		return err
+	}
+	if signature != nil {
+		sigFile := archiveName + signing.SignatureExtension
+		if err := repo.UploadReader(bytes.NewReader(signature), sigFile); err != nil {
+			log.Error("Ошибка загрузки подписи", "файл", sigFile, "ошибка", err.Error())
+			return err
+		}
+	}
+	log.Info("Архив успешно загружен", "файл", archiveName, "sha256", entry.Checksum, "репозиторий", repo.URL())
+
+	return publishToIndex(log, repo, entry)
+}
+
+func newIndexEntry(packet *config.Packet, format, archiveName string) (repository.IndexEntry, error) {
+	checksum, size, err := utils.FileSHA256(archiveName)
+	if err != nil {
+		return repository.IndexEntry{}, err
+	}
+
+	if format == "tgz" {
+		format = "tar.gz"
+	}
+
+	entry := repository.IndexEntry{
+		Name:     packet.Name,
+		Version:  packet.Ver,
+		Format:   format,
+		File:     archiveName,
+		Size:     size,
+		Checksum: checksum,
+	}
+	for _, dep := range packet.Packets {
+		entry.Dependencies = append(entry.Dependencies, repository.Dependency{Name: dep.Name, Ver: dep.Ver})
+	}
+
+	return entry, nil
+}
+
+// publishToIndex перечитывает индекс под блокировкой непосредственно перед
+// записью, чтобы не потерять записи, опубликованные другими пользователями.
+func publishToIndex(log logger.LoggerInterface, repo repository.Repository, entry repository.IndexEntry) error {
+	unlock, err := repository.LockIndex(log, repo)
+	if err != nil {
+		log.Error("Ошибка блокировки индекса", "репозиторий", repo.URL(), "ошибка", err.Error())
+		return err
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			log.Warn("Ошибка снятия блокировки индекса", "файл", repository.IndexLockFile, "ошибка", err.Error())
+		}
+	}()
+
+	idx, err := repository.LoadIndex(repo)
+	if stderrors.Is(err, errors.ErrIndexNotFound) {
+		log.Info("Индекс репозитория не найден, создаётся новый", "репозиторий", repo.URL())
+		idx = &repository.Index{}
+	} else if err != nil {
+		log.Error("Ошибка чтения индекса", "репозиторий", repo.URL(), "ошибка", err.Error())
+		return err
+	}
+
+	idx.Add(entry)
+
+	if err := repository.SaveIndex(repo, idx); err != nil {
+		log.Error("Ошибка обновления индекса", "репозиторий", repo.URL(), "ошибка", err.Error())
+		return err
+	}
+	log.Info("Индекс репозитория обновлён", "репозиторий", repo.URL(), "пакет", entry.Name, "версия", entry.Version)
+
+	for _, dep := range entry.Dependencies {
+		var versions []string
+		for _, e := range idx.Find(dep.Name) {
+			versions = append(versions, e.Version)
+		}
+		i, err := version.Select(versions, dep.Ver, version.Highest)
+		if err != nil {
+			log.Warn("Некорректное условие версии зависимости", "имя", dep.Name, "условие", dep.Ver, "ошибка", err.Error())
+			continue
+		}
+		if i < 0 {
+			log.Warn("Зависимость пока отсутствует в репозитории", "имя", dep.Name, "условие", dep.Ver)
+		}
+	}
+
+	return nil
+}
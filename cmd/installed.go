@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"pm/internal/installed"
+	"pm/internal/logger"
+)
+
+func loadInstalled(root string, log logger.LoggerInterface) (*installed.DB, error) {
+	path := installed.PathFor(root)
+	db, err := installed.Load(path)
+	if err != nil {
+		log.Error("Ошибка чтения базы установленных пакетов", "путь", path, "ошибка", err.Error())
+		return nil, err
+	}
+	return db, nil
+}
+
+func handleList(root string, log logger.LoggerInterface) error {
+	db, err := loadInstalled(root, log)
+	if err != nil {
+		return err
+	}
+	if len(db.Packages) == 0 {
+		log.Info("Установленных пакетов нет", "путь", installed.PathFor(root))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ПАКЕТ\tВЕРСИЯ\tФАЙЛОВ\tУСТАНОВЛЕН\tРЕПОЗИТОРИЙ")
+	for _, p := range db.Packages {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", p.Name, p.Version, len(p.Files), p.Installed.Local().Format("2006-01-02 15:04"), p.Source)
+	}
+	return w.Flush()
+}
+
+func handleInfo(name, root string, log logger.LoggerInterface) error {
+	db, err := loadInstalled(root, log)
+	if err != nil {
+		return err
+	}
+	p, ok := db.Find(name)
+	if !ok {
+		log.Error("Пакет не установлен", "имя", name)
+		return fmt.Errorf("пакет %s не установлен", name)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Пакет:\t%s\n", p.Name)
+	fmt.Fprintf(w, "Версия:\t%s\n", p.Version)
+	if p.Dest != "" {
+		fmt.Fprintf(w, "Директория:\t%s\n", p.Dest)
+	}
+	fmt.Fprintf(w, "Архив:\t%s (%s)\n", p.File, p.Format)
+	fmt.Fprintf(w, "Репозиторий:\t%s\n", p.Source)
+	fmt.Fprintf(w, "SHA-256:\t%s\n", p.Checksum)
+	fmt.Fprintf(w, "Установлен:\t%s\n", p.Installed.Local().Format("2006-01-02 15:04:05"))
+	for i, dep := range p.Dependencies {
+		label := ""
+		if i == 0 {
+			label = "Зависимости:"
+		}
+		fmt.Fprintf(w, "%s\t%s %s\n", label, dep.Name, dep.Ver)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Файлы (%d):\n", len(p.Files))
+	for _, f := range p.Files {
+		if f.Link != "" {
+			fmt.Printf("  %s -> %s\n", f.Path, f.Link)
+			continue
+		}
+		if f.Dir {
+			fmt.Printf("  %s/\n", f.Path)
+			continue
+		}
+		fmt.Printf("  %s\n", f.Path)
+	}
+	return nil
+}
+
+func handleRemove(name string, force bool, root string, log logger.LoggerInterface) error {
+	db, err := loadInstalled(root, log)
+	if err != nil {
+		return err
+	}
+	p, ok := db.Find(name)
+	if !ok {
+		log.Error("Пакет не установлен", "имя", name)
+		return fmt.Errorf("пакет %s не установлен", name)
+	}
+
+	if dependents := db.Dependents(name); len(dependents) > 0 {
+		var names []string
+		for _, d := range dependents {
+			names = append(names, d.Name+"@"+d.Version)
+		}
+		if !force {
+			log.Error("От пакета зависят другие установленные пакеты, используйте --force", "имя", name, "зависимые", names)
+			return fmt.Errorf("от пакета %s зависят: %s", name, strings.Join(names, ", "))
+		}
+		log.Warn("Пакет удаляется, хотя от него зависят другие установленные пакеты", "имя", name, "зависимые", names)
+	}
+
+	result, err := installed.Uninstall(root, db, name)
+	if err != nil {
+		log.Error("Ошибка удаления файлов пакета", "имя", name, "ошибка", err.Error())
+		return err
+	}
+	for _, path := range result.Modified {
+		log.Warn("Файл изменён после установки и оставлен на месте", "файл", path)
+	}
+	for _, path := range result.NotEmpty {
+		log.Warn("Директория пакета не пуста и оставлена на месте", "директория", path)
+	}
+	for _, path := range result.Shared {
+		log.Debug("Файл принадлежит другому пакету и оставлен на месте", "файл", path)
+	}
+	for _, path := range result.Missing {
+		log.Debug("Файл уже удалён", "файл", path)
+	}
+
+	// запись о пакете удаляется и при оставленных изменённых файлах:
+	// они больше не принадлежат pm
+	dbPath := installed.PathFor(root)
+	if err := installed.Save(dbPath, db); err != nil {
+		log.Error("Ошибка записи базы установленных пакетов", "путь", dbPath, "ошибка", err.Error())
+		return err
+	}
+
+	log.Info("Пакет удалён", "имя", p.Name, "версия", p.Version, "удалено_файлов", len(result.Removed), "оставлено_изменённых", len(result.Modified))
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	stderrors "errors"
+	"fmt"
+	"os"
+
+	"pm/config"
+	"pm/internal/errors"
+	"pm/internal/logger"
+)
+
+// handleLint проверяет файл конфигурации и печатает найденные проблемы в
+// формате file:line:col, удобном для редакторов и CI.
+func handleLint(configPath string, log logger.LoggerInterface) error {
+	err := config.Lint(configPath)
+	if err == nil {
+		log.Info("Конфигурация корректна", "файл", configPath)
+		return nil
+	}
+
+	var validationErr *errors.ConfigValidationError
+	if !stderrors.As(err, &validationErr) {
+		log.Error("Ошибка чтения конфигурации", "файл", configPath, "ошибка", err.Error())
+		return err
+	}
+
+	for _, p := range validationErr.Problems {
+		if p.Line > 0 {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", configPath, p)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", configPath, p)
+		}
+	}
+	log.Error("Найдены ошибки в конфигурации", "файл", configPath, "количество", len(validationErr.Problems))
+	return err
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"pm/config"
+	"pm/internal/lock"
+	"pm/internal/logger"
+)
+
+func handleLock(configPath string, opts updateOptions, log logger.LoggerInterface) error {
+	log.Debug("Загрузка конфигурации", "путь", configPath)
+	pkgs, err := config.LoadPackagesConfig(configPath)
+	if err != nil {
+		log.Error("Ошибка загрузки конфигурации", "путь", configPath, "ошибка", err.Error())
+		return err
+	}
+
+	repos, entries, err := resolvePackages(log, opts.Settings, pkgs, opts.Strategy)
+	if err != nil {
+		return err
+	}
+	defer closeRepositories(repos)
+
+	return writeLock(log, lock.PathFor(configPath), pkgs, entries)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	stderrors "errors"
+
+	"pm/internal/errors"
+	"pm/internal/logger"
+	"pm/internal/repository"
+	"pm/internal/settings"
+)
+
+func handleReindex(s *settings.Settings, log logger.LoggerInterface) error {
+	repoURL, err := repositoryURL(log, s)
+	if err != nil {
+		return err
+	}
+	if repoURL == "" {
+		log.Error("Репозиторий не задан: укажите параметр repository или ssh.host")
+		return errors.ErrNoRepository
+	}
+
+	repo, err := openRepository(log, s, repoURL)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	unlock, err := repository.LockIndex(log, repo)
+	if err != nil {
+		log.Error("Ошибка блокировки индекса", "репозиторий", repo.URL(), "ошибка", err.Error())
+		return err
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			log.Warn("Ошибка снятия блокировки индекса", "файл", repository.IndexLockFile, "ошибка", err.Error())
+		}
+	}()
+
+	previous, err := repository.LoadIndex(repo)
+	if err != nil && !stderrors.Is(err, errors.ErrIndexNotFound) {
+		log.Warn("Не удалось прочитать текущий индекс, зависимости не будут перенесены", "ошибка", err.Error())
+	}
+
+	log.Info("Перестроение индекса", "репозиторий", repo.URL())
+	idx, err := repository.Rebuild(log, repo, previous)
+	if err != nil {
+		log.Error("Ошибка построения индекса", "репозиторий", repo.URL(), "ошибка", err.Error())
+		return err
+	}
+
+	if err := repository.SaveIndex(repo, idx); err != nil {
+		log.Error("Ошибка сохранения индекса", "репозиторий", repo.URL(), "ошибка", err.Error())
+		return err
+	}
+
+	log.Info("Индекс перестроен", "репозиторий", repo.URL(), "пакетов", len(idx.Packages))
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"pm/config"
+	"pm/internal/logger"
+	"pm/internal/repository"
+	"pm/internal/settings"
+	"pm/internal/ssh"
+)
+
+// sshConfig собирает параметры SSH из настроек репозитория.
+func sshConfig(log logger.LoggerInterface, s *settings.Settings) (ssh.Config, *ssh.SSHConfigFile, error) {
+	policy, err := ssh.ParseHostKeyPolicy(s.String("ssh.host_key_checking"))
+	if err != nil {
+		return ssh.Config{}, nil, err
+	}
+	if policy == ssh.HostKeyOff {
+		log.Warn("Проверка ключа хоста отключена: соединение уязвимо для атаки посредника")
+	}
+
+	authMethods, err := ssh.ParseAuthMethods(s.String("ssh.auth"))
+	if err != nil {
+		return ssh.Config{}, nil, err
+	}
+
+	cfg := ssh.Config{
+		User:           s.String("ssh.user"),
+		Host:           s.String("ssh.host"),
+		Port:           s.Int("ssh.port"),
+		KeyPath:        s.String("ssh.key"),
+		KeyPassphrase:  os.Getenv("PM_SSH_KEY_PASSPHRASE"),
+		CertPath:       s.String("ssh.cert"),
+		AgentSocket:    os.Getenv("SSH_AUTH_SOCK"),
+		AuthMethods:    authMethods,
+		KnownHostsPath: s.String("ssh.known_hosts"),
+		HostKeyPolicy:  policy,
+		HashKnownHosts: s.Bool("ssh.hash_known_hosts"),
+	}
+
+	sshConfigPath := s.String("ssh.config")
+	if sshConfigPath == "" {
+		sshConfigPath = ssh.DefaultSSHConfigPath()
+	}
+	if sshConfigPath == "none" || sshConfigPath == "" {
+		return cfg, nil, nil
+	}
+
+	sshConfigFile, err := ssh.LoadSSHConfig(sshConfigPath)
+	if err != nil {
+		log.Error("Ошибка чтения SSH конфигурации", "путь", sshConfigPath, "ошибка", err.Error())
+		return ssh.Config{}, nil, err
+	}
+
+	return cfg, sshConfigFile, nil
+}
+
+// repositoryURL возвращает адрес выбранного в настройках репозитория.
+// Пустая строка без ошибки означает, что репозиторий не настроен.
+func repositoryURL(log logger.LoggerInterface, s *settings.Settings) (string, error) {
+	name, repoURL, err := s.Repository()
+	if err != nil {
+		log.Error("Ошибка выбора репозитория", "ошибка", err.Error())
+		return "", err
+	}
+	if name != "" {
+		log.Debug("Используется именованный репозиторий", "имя", name, "адрес", repoURL)
+	}
+	return repoURL, nil
+}
+
+func openRepository(log logger.LoggerInterface, s *settings.Settings, repoURL string) (repository.Repository, error) {
+	return openNamedRepository(log, s, s.RepositoryName(repoURL), repoURL)
+}
+
+// openNamedRepository открывает репозиторий, применяя SSH-параметры
+// репозитория name из настроек.
+func openNamedRepository(log logger.LoggerInterface, s *settings.Settings, name, repoURL string) (repository.Repository, error) {
+	sshCfg, sshConfigFile, err := sshConfig(log, s.ForRepository(name))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Открытие репозитория", "адрес", repoURL)
+	repo, err := repository.Open(repoURL, repository.Options{
+		Log:           log,
+		SSH:           sshCfg,
+		SSHConfigFile: sshConfigFile,
+	})
+	if err != nil {
+		log.Error("Ошибка открытия репозитория", "адрес", repoURL, "ошибка", err.Error())
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func closeRepositories(repos map[string]repository.Repository) {
+	for _, repo := range repos {
+		repo.Close()
+	}
+}
+
+// packageSource — репозиторий, в котором ищутся пакеты при установке.
+type packageSource struct {
+	name     string
+	url      string
+	priority int
+}
+
+// packageSources возвращает репозитории для установки в порядке приоритета:
+// именованные репозитории из настроек вместе с объявленными в packages.json.
+// Репозиторий, заданный адресом в параметре repository, просматривается
+// первым, остальные остаются доступны для закреплённых пакетов. Если
+// именованных репозиториев нет, используется единственный репозиторий из
+// настроек. Пустой список означает, что репозиторий не настроен.
+func packageSources(s *settings.Settings, pkgs *config.Packages) ([]packageSource, error) {
+	name, repoURL, err := s.Repository()
+	if err != nil {
+		return nil, err
+	}
+	explicit := repoURL != "" && name == "" && s.IsSet("repository")
+
+	var sources []*packageSource
+	byName := make(map[string]*packageSource)
+	for _, r := range s.Repositories() {
+		src := &packageSource{name: r.Name, url: r.URL, priority: r.Priority}
+		sources = append(sources, src)
+		byName[r.Name] = src
+	}
+	for _, r := range pkgs.Repositories {
+		src, ok := byName[r.Name]
+		if !ok {
+			if r.URL == "" {
+				return nil, fmt.Errorf("репозиторий %q из packages.json не описан в настройках pm: укажите url", r.Name)
+			}
+			src = &packageSource{name: r.Name, priority: settings.DefaultPriority}
+			sources = append(sources, src)
+			byName[r.Name] = src
+		}
+		if r.URL != "" {
+			src.url = r.URL
+		}
+		if r.Priority != 0 {
+			src.priority = r.Priority
+		}
+	}
+
+	for _, pkg := range pkgs.Packages {
+		if pkg.Repository != "" && byName[pkg.Repository] == nil {
+			return nil, fmt.Errorf("пакет %s закреплён за неизвестным репозиторием %q", pkg.Name, pkg.Repository)
+		}
+	}
+
+	if len(sources) == 0 {
+		if repoURL == "" {
+			return nil, nil
+		}
+		return []packageSource{{url: repoURL}}, nil
+	}
+	if explicit {
+		first := &packageSource{url: repoURL}
+		for _, src := range sources {
+			if src.url == repoURL {
+				first = src
+				break
+			}
+		}
+		if first.name == "" {
+			sources = append(sources, first)
+		}
+		first.priority = math.MinInt
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i].priority != sources[j].priority {
+			return sources[i].priority < sources[j].priority
+		}
+		return sources[i].name < sources[j].name
+	})
+	result := make([]packageSource, len(sources))
+	for i, src := range sources {
+		result[i] = *src
+	}
+	return result, nil
+}
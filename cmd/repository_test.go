@@ -0,0 +1,132 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"pm/config"
+	"pm/internal/settings"
+)
+
+// loadSettings загружает настройки только из флагов -c, чтобы тест не
+// зависел от файлов и окружения машины.
+func loadSettings(t *testing.T, overrides map[string]string) *settings.Settings {
+	t.Helper()
+	for _, k := range settings.Keys {
+		t.Setenv(k.Env, "")
+	}
+	dir := t.TempDir()
+	t.Setenv("PM_SYSTEM_CONFIG", filepath.Join(dir, "system.yaml"))
+	t.Setenv("PM_CONFIG", filepath.Join(dir, "user.yaml"))
+	t.Chdir(dir)
+
+	s, err := settings.Load(settings.Options{Overrides: overrides})
+	if err != nil {
+		t.Fatalf("Ошибка загрузки настроек: %v", err)
+	}
+	return s
+}
+
+func TestPackageSources(t *testing.T) {
+	named := map[string]string{
+		"repositories.internal.url":      "file:///srv/internal",
+		"repositories.internal.priority": "10",
+		"repositories.mirror.url":        "file:///srv/mirror",
+	}
+	with := func(extra map[string]string) map[string]string {
+		m := map[string]string{}
+		for k, v := range named {
+			m[k] = v
+		}
+		for k, v := range extra {
+			m[k] = v
+		}
+		return m
+	}
+
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		pkgs      config.Packages
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "нет репозиториев",
+			overrides: map[string]string{},
+			want:      nil,
+		},
+		{
+			name:      "единственный адрес",
+			overrides: map[string]string{"repository": "file:///srv/override"},
+			want:      []string{"=file:///srv/override"},
+		},
+		{
+			name:      "именованные репозитории по приоритету",
+			overrides: named,
+			want:      []string{"internal=file:///srv/internal", "mirror=file:///srv/mirror"},
+		},
+		{
+			name:      "адрес просматривается первым, именованные остаются",
+			overrides: with(map[string]string{"repository": "file:///srv/override"}),
+			pkgs:      config.Packages{Packages: []config.Packet{{Name: "app", Repository: "mirror"}}},
+			want:      []string{"=file:///srv/override", "internal=file:///srv/internal", "mirror=file:///srv/mirror"},
+		},
+		{
+			name:      "адрес и репозитории из packages.json",
+			overrides: map[string]string{"repository": "file:///srv/override"},
+			pkgs: config.Packages{
+				Repositories: []config.Repository{{Name: "vendor", URL: "file:///srv/vendor"}},
+				Packages:     []config.Packet{{Name: "lib", Repository: "vendor"}},
+			},
+			want: []string{"=file:///srv/override", "vendor=file:///srv/vendor"},
+		},
+		{
+			name:      "адрес совпадает с именованным репозиторием",
+			overrides: with(map[string]string{"repository": "file:///srv/mirror"}),
+			want:      []string{"mirror=file:///srv/mirror", "internal=file:///srv/internal"},
+		},
+		{
+			name:      "выбран именованный репозиторий",
+			overrides: with(map[string]string{"repository": "mirror"}),
+			want:      []string{"internal=file:///srv/internal", "mirror=file:///srv/mirror"},
+		},
+		{
+			name:      "закрепление за неизвестным репозиторием при заданном адресе",
+			overrides: map[string]string{"repository": "file:///srv/override"},
+			pkgs:      config.Packages{Packages: []config.Packet{{Name: "app", Repository: "mirror"}}},
+			wantErr:   true,
+		},
+		{
+			name:      "репозиторий packages.json без адреса",
+			overrides: named,
+			pkgs:      config.Packages{Repositories: []config.Repository{{Name: "vendor"}}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := loadSettings(t, tt.overrides)
+			sources, err := packageSources(s, &tt.pkgs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Ожидалась ошибка, получено %v", sources)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+
+			var got []string
+			for _, src := range sources {
+				got = append(got, src.name+"="+src.url)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("packageSources() = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"pm/internal/errors"
+	"pm/internal/logger"
+	"pm/internal/repository"
+	"pm/internal/settings"
+	"pm/internal/signing"
+	"pm/internal/utils"
+)
+
+func handleKeygen(keyPath, comment string, log logger.LoggerInterface) error {
+	if keyPath == "" {
+		keyPath = signing.DefaultKeyPath()
+	}
+
+	fingerprint, err := signing.GenerateKey(keyPath, comment)
+	if err != nil {
+		log.Error("Ошибка создания ключа подписи", "путь", keyPath, "ошибка", err.Error())
+		return err
+	}
+
+	log.Info("Ключ подписи создан", "закрытый", keyPath, "открытый", keyPath+signing.PublicKeyExtension, "отпечаток", fingerprint)
+	return nil
+}
+
+func handleSign(archivePath, keyPath string, s *settings.Settings, log logger.LoggerInterface) error {
+	if keyPath == "" {
+		keyPath = signingKeyPath(s)
+	}
+
+	key, err := signing.LoadPrivateKey(keyPath, os.Getenv("PM_SIGNING_KEY_PASSPHRASE"))
+	if err != nil {
+		log.Error("Ошибка чтения ключа подписи", "путь", keyPath, "ошибка", err.Error())
+		return err
+	}
+
+	checksum, _, err := utils.FileSHA256(archivePath)
+	if err != nil {
+		log.Error("Ошибка вычисления контрольной суммы", "файл", archivePath, "ошибка", err.Error())
+		return err
+	}
+
+	sig := signing.Sign(key, filepath.Base(archivePath), checksum)
+	data, err := sig.Marshal()
+	if err != nil {
+		return err
+	}
+
+	sigPath := archivePath + signing.SignatureExtension
+	if err := os.WriteFile(sigPath, data, 0644); err != nil {
+		log.Error("Ошибка записи подписи", "путь", sigPath, "ошибка", err.Error())
+		return err
+	}
+
+	log.Info("Архив подписан", "архив", archivePath, "подпись", sigPath, "ключ", sig.Key)
+	return nil
+}
+
+func handleVerify(archivePath, sigPath, trustedPath string, s *settings.Settings, log logger.LoggerInterface) error {
+	if sigPath == "" {
+		sigPath = archivePath + signing.SignatureExtension
+	}
+	if trustedPath == "" {
+		trustedPath = trustedKeysPath(s)
+	}
+
+	trusted, err := signing.LoadTrustedKeys(trustedPath)
+	if err != nil {
+		log.Error("Ошибка чтения доверенных ключей", "путь", trustedPath, "ошибка", err.Error())
+		return err
+	}
+	if trusted.Len() == 0 {
+		log.Error("Список доверенных ключей пуст", "путь", trustedPath)
+		return errors.NewSignatureError(archivePath, fmt.Errorf("не заданы доверенные ключи (%s)", trustedPath))
+	}
+
+	data, err := os.ReadFile(sigPath)
+	if err != nil {
+		log.Error("Ошибка чтения подписи", "путь", sigPath, "ошибка", err.Error())
+		return err
+	}
+	sig, err := signing.ParseSignature(data)
+	if err != nil {
+		log.Error("Ошибка чтения подписи", "путь", sigPath, "ошибка", err.Error())
+		return err
+	}
+
+	checksum, _, err := utils.FileSHA256(archivePath)
+	if err != nil {
+		return err
+	}
+
+	if err := trusted.Verify(sig, filepath.Base(archivePath), checksum); err != nil {
+		log.Error("Подпись не прошла проверку", "архив", archivePath, "ошибка", err.Error())
+		return err
+	}
+
+	log.Info("Подпись действительна", "архив", archivePath, "ключ", sig.Key)
+	return nil
+}
+
+func signingKeyPath(s *settings.Settings) string {
+	if path := s.String("signing.key"); path != "" {
+		return path
+	}
+	return signing.DefaultKeyPath()
+}
+
+func trustedKeysPath(s *settings.Settings) string {
+	if path := s.String("signing.trusted_keys"); path != "" {
+		return path
+	}
+	return signing.DefaultTrustedKeysPath()
+}
+
+// signArchive подписывает архив при публикации. Если ключ не задан явно
+// параметром signing.key и ключа по умолчанию нет, архив публикуется без подписи.
+func signArchive(log logger.LoggerInterface, s *settings.Settings, archiveName, checksum string) ([]byte, error) {
+	keyPath := signingKeyPath(s)
+	if s.String("signing.key") == "" {
+		if _, err := os.Stat(keyPath); err != nil {
+			log.Debug("Ключ подписи не найден, архив публикуется без подписи", "путь", keyPath)
+			return nil, nil
+		}
+	}
+
+	key, err := signing.LoadPrivateKey(keyPath, os.Getenv("PM_SIGNING_KEY_PASSPHRASE"))
+	if err != nil {
+		log.Error("Ошибка чтения ключа подписи", "путь", keyPath, "ошибка", err.Error())
+		return nil, err
+	}
+
+	sig := signing.Sign(key, archiveName, checksum)
+	log.Debug("Архив подписан", "файл", archiveName, "ключ", sig.Key)
+	return sig.Marshal()
+}
+
+type signaturePolicy struct {
+	trusted *signing.KeyRing
+	require bool
+}
+
+func newSignaturePolicy(log logger.LoggerInterface, s *settings.Settings, require bool) (*signaturePolicy, error) {
+	if s.Bool("signing.require") {
+		require = true
+	}
+
+	path := trustedKeysPath(s)
+	trusted, err := signing.LoadTrustedKeys(path)
+	if err != nil {
+		log.Error("Ошибка чтения доверенных ключей", "путь", path, "ошибка", err.Error())
+		return nil, err
+	}
+	log.Debug("Доверенные ключи загружены", "путь", path, "ключей", trusted.Len(), "обязательная_подпись", require)
+
+	if require && trusted.Len() == 0 {
+		log.Error("Подписи обязательны, но список доверенных ключей пуст", "путь", path)
+		return nil, fmt.Errorf("подписи обязательны, но не задано ни одного доверенного ключа (%s)", path)
+	}
+
+	return &signaturePolicy{trusted: trusted, require: require}, nil
+}
+
+// verify проверяет подпись скачанного архива. checksum вычислена по
+// скачанному файлу, поэтому подпись покрывает именно его содержимое.
+func (p *signaturePolicy) verify(log logger.LoggerInterface, repo repository.Repository, entry repository.IndexEntry, checksum string) error {
+	sigFile := entry.File + signing.SignatureExtension
+	tmp, err := os.CreateTemp("", "pm-*"+signing.SignatureExtension)
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	localSig := tmp.Name()
+	defer os.Remove(localSig)
+
+	if err := repo.Download(sigFile, localSig); err != nil {
+		if !stderrors.Is(err, os.ErrNotExist) {
+			log.Error("Ошибка скачивания подписи", "файл", sigFile, "ошибка", err.Error())
+			return err
+		}
+		if p.require {
+			log.Error("Пакет не подписан, установка отменена", "имя", entry.Name, "файл", entry.File)
+			return errors.NewSignatureError(entry.File, errors.ErrUnsignedPackage)
+		}
+		if p.trusted.Len() > 0 {
+			log.Warn("Пакет не подписан", "имя", entry.Name, "файл", entry.File)
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(localSig)
+	if err != nil {
+		return err
+	}
+	sig, err := signing.ParseSignature(data)
+	if err != nil {
+		log.Error("Ошибка чтения подписи", "файл", sigFile, "ошибка", err.Error())
+		return errors.NewSignatureError(entry.File, err)
+	}
+
+	if p.trusted.Len() == 0 {
+		log.Warn("Подпись не проверена: не заданы доверенные ключи", "файл", entry.File, "ключ", sig.Key)
+		return nil
+	}
+
+	if err := p.trusted.Verify(sig, entry.File, checksum); err != nil {
+		log.Error("Подпись не прошла проверку, установка отменена", "файл", entry.File, "ошибка", err.Error())
+		return err
+	}
+
+	log.Debug("Подпись действительна", "файл", entry.File, "ключ", sig.Key)
+	return nil
+}
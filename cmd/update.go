@@ -0,0 +1,777 @@
+package main
+
+import (
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"pm/config"
+	"pm/internal/archive"
+	"pm/internal/cache"
+	"pm/internal/errors"
+	"pm/internal/installed"
+	"pm/internal/lock"
+	"pm/internal/logger"
+	"pm/internal/repository"
+	"pm/internal/resolver"
+	"pm/internal/settings"
+	"pm/internal/transaction"
+	"pm/internal/utils"
+	"pm/pkg/version"
+)
+
+type updateOptions struct {
+	Settings          *settings.Settings
+	Root              string
+	Strategy          version.Strategy
+	Frozen            bool
+	RequireSignatures bool
+	Limits            archive.Limits
+}
+
+// installOptions — общие для всех пакетов параметры установки.
+type installOptions struct {
+	policy *signaturePolicy
+	limits archive.Limits
+	cache  *cache.Cache
+	root   string
+	// dests — директории установки пакетов относительно root из поля dest
+	// packages.json.
+	dests map[string]string
+	// constraints — ограничения версий пакетов из packages.json.
+	// Установленная версия, которая им удовлетворяет, остаётся на месте, если
+	// не выбрана более новая и итоговый набор пакетов остаётся согласованным
+	// (см. keptPackages). nil при установке по lock-файлу: тогда ставятся
+	// ровно зафиксированные версии.
+	constraints map[string][]string
+}
+
+func handleUpdate(configPath string, opts updateOptions, log logger.LoggerInterface) error {
+	log.Debug("Загрузка конфигурации", "путь", configPath)
+	pkgs, err := config.LoadPackagesConfig(configPath)
+	if err != nil {
+		log.Error("Ошибка загрузки конфигурации", "путь", configPath, "ошибка", err.Error())
+		return err
+	}
+
+	policy, err := newSignaturePolicy(log, opts.Settings, opts.RequireSignatures)
+	if err != nil {
+		return err
+	}
+
+	c, err := openCache(log, opts.Settings)
+	if err != nil {
+		return err
+	}
+
+	install := installOptions{policy: policy, limits: opts.Limits, cache: c, root: opts.Root, dests: make(map[string]string)}
+	for _, pkg := range pkgs.Packages {
+		if pkg.Dest != "" {
+			install.dests[pkg.Name] = path.Clean(filepath.ToSlash(pkg.Dest))
+		}
+	}
+
+	lockPath := lock.PathFor(configPath)
+	if opts.Frozen {
+		return installFromLock(log, opts.Settings, pkgs, lockPath, install)
+	}
+
+	repos, entries, err := resolvePackages(log, opts.Settings, pkgs, opts.Strategy)
+	if err != nil {
+		return err
+	}
+	defer closeRepositories(repos)
+
+	install.constraints = versionConstraints(pkgs, nil)
+	entries, err = installPackages(log, repos, entries, install)
+	if err != nil {
+		return err
+	}
+
+	return writeLock(log, lockPath, pkgs, entries)
+}
+
+// resolvePackages разрешает зависимости по объединённому индексу всех
+// репозиториев. Возвращает открытые репозитории по адресу — записи индекса
+// ссылаются на них через Source.
+func resolvePackages(log logger.LoggerInterface, s *settings.Settings, pkgs *config.Packages, strategy version.Strategy) (map[string]repository.Repository, []repository.IndexEntry, error) {
+	sources, err := packageSources(s, pkgs)
+	if err != nil {
+		log.Error("Ошибка выбора репозиториев", "ошибка", err.Error())
+		return nil, nil, err
+	}
+	if len(sources) == 0 {
+		log.Error("Репозиторий не задан: укажите параметр repository или ssh.host")
+		return nil, nil, errors.ErrNoRepository
+	}
+
+	pinned := make(map[string]bool)
+	for _, pkg := range pkgs.Packages {
+		if pkg.Repository != "" {
+			pinned[pkg.Repository] = true
+		}
+	}
+
+	repos := make(map[string]repository.Repository)
+	urls := make(map[string]string)
+	var indexes []*repository.Index
+	for _, src := range sources {
+		repo, idx, err := openSource(log, s, src)
+		if err != nil {
+			// недоступный репозиторий пропускается, если пакеты можно
+			// найти в остальных
+			if len(sources) == 1 || pinned[src.name] {
+				closeRepositories(repos)
+				return nil, nil, err
+			}
+			log.Warn("Репозиторий недоступен, пакеты ищутся в следующих", "репозиторий", src.url, "ошибка", err.Error())
+			continue
+		}
+		repos[repo.URL()] = repo
+		urls[src.name] = repo.URL()
+		indexes = append(indexes, idx)
+	}
+	if len(indexes) == 0 {
+		log.Error("Ни один из репозиториев недоступен", "количество", len(sources))
+		return nil, nil, fmt.Errorf("ни один из %d репозиториев недоступен", len(sources))
+	}
+
+	pins := make(map[string]string)
+	var roots []resolver.Requirement
+	for _, pkg := range pkgs.Packages {
+		roots = append(roots, resolver.Requirement{Name: pkg.Name, Constraint: pkg.Ver})
+		if pkg.Repository != "" {
+			pins[pkg.Name] = urls[pkg.Repository]
+		}
+	}
+	idx := repository.Merge(indexes, pins)
+
+	log.Debug("Разрешение зависимостей", "пакетов", len(roots), "репозиториев", len(indexes), "стратегия", strategy)
+	entries, err := resolver.New(log, idx, strategy).Resolve(roots)
+	if err != nil {
+		log.Error("Ошибка разрешения зависимостей", "ошибка", err.Error())
+		closeRepositories(repos)
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		log.Info("Выбран пакет", "имя", entry.Name, "версия", entry.Version, "формат", entry.Format, "файл", entry.File, "репозиторий", entry.Source)
+	}
+
+	return repos, entries, nil
+}
+
+func openSource(log logger.LoggerInterface, s *settings.Settings, src packageSource) (repository.Repository, *repository.Index, error) {
+	repo, err := openNamedRepository(log, s, src.name, src.url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idx, err := loadIndex(log, repo)
+	if err != nil {
+		repo.Close()
+		return nil, nil, err
+	}
+	return repo, idx, nil
+}
+
+func installFromLock(log logger.LoggerInterface, s *settings.Settings, pkgs *config.Packages, lockPath string, install installOptions) error {
+	log.Debug("Чтение lock-файла", "путь", lockPath)
+	l, err := lock.Load(lockPath)
+	if err != nil {
+		log.Error("Ошибка чтения lock-файла", "путь", lockPath, "ошибка", err.Error())
+		return err
+	}
+
+	if err := l.Check(pkgs); err != nil {
+		log.Error("Lock-файл не соответствует конфигурации", "путь", lockPath)
+		return err
+	}
+
+	bySource := l.Entries()
+	repos := make(map[string]repository.Repository)
+	defer closeRepositories(repos)
+
+	var entries []repository.IndexEntry
+	for source, sourceEntries := range bySource {
+		repo, err := openRepository(log, s, source)
+		if err != nil {
+			return err
+		}
+		repos[source] = repo
+		entries = append(entries, sourceEntries...)
+	}
+
+	log.Info("Установка по lock-файлу", "путь", lockPath, "пакетов", len(entries))
+	_, err = installPackages(log, repos, entries, install)
+	return err
+}
+
+func writeLock(log logger.LoggerInterface, lockPath string, pkgs *config.Packages, entries []repository.IndexEntry) error {
+	if err := lock.Save(lockPath, lock.New(pkgs, entries)); err != nil {
+		log.Error("Ошибка записи lock-файла", "путь", lockPath, "ошибка", err.Error())
+		return err
+	}
+	log.Info("Lock-файл обновлён", "путь", lockPath, "пакетов", len(entries))
+	return nil
+}
+
+// Итог установки пакета для сводки pm update.
+const (
+	statusInstalled   = "установлен"
+	statusUpgraded    = "обновлён"
+	statusDowngraded  = "понижен"
+	statusReinstalled = "переустановлен"
+	statusUnchanged   = "без изменений"
+)
+
+type installResult struct {
+	name     string
+	previous string
+	version  string
+	status   string
+}
+
+// stagedPackage — пакет, распакованный во временную директорию
+// транзакции, но ещё не перенесённый в корень установки.
+type stagedPackage struct {
+	pkg   installed.Package
+	stage string
+}
+
+// installPackages устанавливает пакеты одной транзакцией: все архивы
+// распаковываются во временные директории и переносятся на место, только
+// если ни один пакет не завершился ошибкой. Ошибка переноса отменяет уже
+// перенесённые пакеты. Возвращает записи фактически установленных версий:
+// оставленная на месте версия заменяет выбранную, чтобы её зафиксировал
+// lock-файл.
+func installPackages(log logger.LoggerInterface, repos map[string]repository.Repository, entries []repository.IndexEntry, install installOptions) ([]repository.IndexEntry, error) {
+	dbPath := installed.PathFor(install.root)
+	db, err := installed.Load(dbPath)
+	if err != nil {
+		log.Error("Ошибка чтения базы установленных пакетов", "путь", dbPath, "ошибка", err.Error())
+		return nil, err
+	}
+
+	kept := keptPackages(log, db, entries, install)
+
+	var results []installResult
+	var pending []repository.IndexEntry
+	final := make([]repository.IndexEntry, 0, len(entries))
+	for _, entry := range entries {
+		if current, ok := kept[entry.Name]; ok {
+			log.Debug("Установленная версия удовлетворяет ограничениям", "имя", entry.Name, "установлена", current.Version, "выбрана", entry.Version)
+			results = append(results, installResult{name: entry.Name, previous: current.Version, version: current.Version, status: statusUnchanged})
+			final = append(final, installedEntry(current))
+			continue
+		}
+		current, ok := db.Find(entry.Name)
+		if ok && isInstalled(current, entry, install.dests[entry.Name]) {
+			log.Debug("Пакет уже установлен", "имя", entry.Name, "версия", entry.Version)
+			results = append(results, installResult{name: entry.Name, previous: current.Version, version: entry.Version, status: statusUnchanged})
+			final = append(final, entry)
+			continue
+		}
+		pending = append(pending, entry)
+		final = append(final, entry)
+	}
+	if len(pending) == 0 {
+		printInstallSummary(results)
+		return final, nil
+	}
+
+	tx, err := transaction.Begin(install.root, installed.Dir)
+	if err != nil {
+		log.Error("Ошибка создания временной директории установки", "ошибка", err.Error())
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, maxConcurrentOps)
+	errs := make(chan error, len(pending))
+	var staged []stagedPackage
+
+	for _, entry := range pending {
+		wg.Add(1)
+		go func(entry repository.IndexEntry) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			sp, err := stagePackage(log, repos[entry.Source], entry, tx, db, install)
+			if err != nil {
+				errs <- err
+				return
+			}
+			mu.Lock()
+			staged = append(staged, sp)
+			mu.Unlock()
+		}(entry)
+	}
+
+	wg.Wait()
+	close(errs)
+	evictCache(log, install.cache)
+
+	var allErrors []error
+	for err := range errs {
+		allErrors = append(allErrors, err)
+	}
+
+	if len(allErrors) > 0 {
+		rollback(log, tx)
+		log.Error("Ошибки при установке пакетов, ни один пакет не установлен", "количество", len(allErrors))
+		for i, err := range allErrors {
+			log.Error("Ошибка установки пакета", "номер", i+1, "ошибка", err.Error())
+		}
+		return nil, fmt.Errorf("ошибок при установке: %d, первая: %w", len(allErrors), allErrors[0])
+	}
+
+	// перенос выполняется последовательно и в одном порядке: файлы,
+	// общие для нескольких пакетов, всегда достаются одному из них
+	sort.Slice(staged, func(i, j int) bool { return staged[i].pkg.Name < staged[j].pkg.Name })
+	for _, sp := range staged {
+		result := installResult{name: sp.pkg.Name, version: sp.pkg.Version, status: statusInstalled}
+		if previous, ok := db.Find(sp.pkg.Name); ok {
+			result.previous = previous.Version
+			result.status = versionChange(previous.Version, sp.pkg.Version)
+		}
+
+		if err := applyPackage(log, tx, db, sp, install.root); err != nil {
+			rollback(log, tx)
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if err := installed.Save(dbPath, db); err != nil {
+		log.Error("Ошибка записи базы установленных пакетов", "путь", dbPath, "ошибка", err.Error())
+		rollback(log, tx)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Warn("Ошибка удаления резервных копий", "путь", tx.Dir(), "ошибка", err.Error())
+	}
+
+	for _, sp := range staged {
+		log.Info("Пакет успешно установлен", "имя", sp.pkg.Name, "версия", sp.pkg.Version, "формат", sp.pkg.Format)
+	}
+	printInstallSummary(results)
+	return final, nil
+}
+
+// applyPackage переносит распакованный пакет в корень установки и
+// убирает файлы его прежней версии.
+func applyPackage(log logger.LoggerInterface, tx *transaction.Transaction, db *installed.DB, sp stagedPackage, root string) error {
+	log.Debug("Перенос пакета в директорию установки", "имя", sp.pkg.Name, "из", sp.stage)
+	if err := tx.Apply(sp.stage); err != nil {
+		log.Error("Ошибка переноса файлов пакета", "имя", sp.pkg.Name, "ошибка", err.Error())
+		return errors.NewArchiveExtractionError(sp.pkg.File, root, err)
+	}
+
+	stale, err := installed.Replace(root, db, sp.pkg, tx.Remove)
+	if err != nil {
+		log.Error("Ошибка удаления файлов прежней версии", "имя", sp.pkg.Name, "ошибка", err.Error())
+		return err
+	}
+	for _, path := range stale.Modified {
+		log.Warn("Файл прежней версии изменён и оставлен на месте", "имя", sp.pkg.Name, "файл", path)
+	}
+	for _, path := range stale.NotEmpty {
+		log.Debug("Директория прежней версии не пуста и оставлена на месте", "имя", sp.pkg.Name, "директория", path)
+	}
+	return nil
+}
+
+func rollback(log logger.LoggerInterface, tx *transaction.Transaction) {
+	if err := tx.Rollback(); err != nil {
+		log.Error("Ошибка отката установки, резервные копии сохранены", "путь", tx.Dir(), "ошибка", err.Error())
+		return
+	}
+	log.Warn("Установка отменена, файлы восстановлены")
+}
+
+// isInstalled сообщает, установлена ли уже выбранная версия пакета в
+// директорию dest. Архив с той же версией, но другой контрольной суммой
+// (пакет перевыпущен) устанавливается заново.
+func isInstalled(current installed.Package, entry repository.IndexEntry, dest string) bool {
+	if current.Version != entry.Version || current.Dest != dest {
+		return false
+	}
+	return entry.Checksum == "" || current.Checksum == entry.Checksum
+}
+
+// keepInstalled сообщает, можно ли оставить установленную версию пакета
+// вместо выбранной: она удовлетворяет всем ограничениям, а выбранная версия
+// не новее. Так pm update не понижает пакеты при --prefer-lowest и когда
+// установлена версия новее, чем есть в репозитории.
+func keepInstalled(current installed.Package, entry repository.IndexEntry, dest string, constraints map[string][]string) bool {
+	if constraints == nil || current.Dest != dest || current.Version == entry.Version {
+		return false
+	}
+
+	installedVersion, err := version.Parse(current.Version)
+	if err != nil {
+		return false
+	}
+	selected, err := version.Parse(entry.Version)
+	if err != nil || selected.GreaterThan(installedVersion) {
+		return false
+	}
+
+	for _, constraint := range constraints[entry.Name] {
+		if ok, err := version.Matches(current.Version, constraint); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// keptPackages выбирает пакеты, установленные версии которых остаются на
+// месте вместо выбранных резолвером. Кандидаты отбирает keepInstalled, затем
+// набор проверяется целиком: зависимости каждого пакета итогового набора,
+// включая оставленные версии, должны выполняться итоговыми версиями. Пакет,
+// нарушающий это, возвращается к выбранной версии, и проверка повторяется:
+// в худшем случае остаётся ровно выбор резолвера, который согласован.
+func keptPackages(log logger.LoggerInterface, db *installed.DB, entries []repository.IndexEntry, install installOptions) map[string]installed.Package {
+	kept := make(map[string]installed.Package)
+	if install.constraints == nil {
+		return kept
+	}
+	for _, entry := range entries {
+		current, ok := db.Find(entry.Name)
+		if ok && keepInstalled(current, entry, install.dests[entry.Name], install.constraints) {
+			kept[entry.Name] = current
+		}
+	}
+
+	for len(kept) > 0 {
+		final := make(map[string]repository.IndexEntry, len(entries))
+		for _, entry := range entries {
+			if current, ok := kept[entry.Name]; ok {
+				entry = installedEntry(current)
+			}
+			final[entry.Name] = entry
+		}
+
+		conflict := ""
+		for _, entry := range entries {
+			entry = final[entry.Name]
+			for _, dep := range entry.Dependencies {
+				target, ok := final[dep.Name]
+				if ok {
+					if matches, err := version.Matches(target.Version, dep.Ver); err == nil && matches {
+						continue
+					}
+				}
+				// оставленный пакет с неудовлетворённой зависимостью
+				// обновляется, иначе заменяется сама зависимость
+				if _, ok := kept[entry.Name]; ok {
+					conflict = entry.Name
+				} else if _, ok := kept[dep.Name]; ok {
+					conflict = dep.Name
+				}
+				if conflict != "" {
+					log.Debug("Установленная версия не согласуется с зависимостями", "имя", conflict, "зависимость", dep.Name, "условие", dep.Ver)
+					break
+				}
+			}
+			if conflict != "" {
+				break
+			}
+		}
+		if conflict == "" {
+			break
+		}
+		delete(kept, conflict)
+	}
+	return kept
+}
+
+// installedEntry описывает установленную версию пакета записью индекса.
+func installedEntry(p installed.Package) repository.IndexEntry {
+	return repository.IndexEntry{
+		Name:         p.Name,
+		Version:      p.Version,
+		Format:       p.Format,
+		File:         p.File,
+		Checksum:     p.Checksum,
+		Dependencies: p.Dependencies,
+		Source:       p.Source,
+	}
+}
+
+// versionConstraints собирает ограничения версий каждого пакета: из
+// packages.json и из зависимостей выбранных пакетов.
+func versionConstraints(pkgs *config.Packages, entries []repository.IndexEntry) map[string][]string {
+	constraints := make(map[string][]string)
+	for _, pkg := range pkgs.Packages {
+		constraints[pkg.Name] = append(constraints[pkg.Name], pkg.Ver)
+	}
+	for _, entry := range entries {
+		for _, dep := range entry.Dependencies {
+			constraints[dep.Name] = append(constraints[dep.Name], dep.Ver)
+		}
+	}
+	return constraints
+}
+
+func versionChange(previous, current string) string {
+	if previous == current {
+		return statusReinstalled
+	}
+	prev, err1 := version.Parse(previous)
+	cur, err2 := version.Parse(current)
+	if err1 == nil && err2 == nil && cur.LessThan(prev) {
+		return statusDowngraded
+	}
+	return statusUpgraded
+}
+
+func printInstallSummary(results []installResult) {
+	if len(results) == 0 {
+		return
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].name < results[j].name })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ПАКЕТ\tБЫЛО\tСТАЛО\tИТОГ")
+	for _, r := range results {
+		previous := r.previous
+		if previous == "" {
+			previous = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.name, previous, r.version, r.status)
+	}
+	w.Flush()
+}
+
+// stagePackage скачивает (или берёт из кэша), проверяет и распаковывает
+// пакет во временную директорию транзакции.
+func stagePackage(log logger.LoggerInterface, repo repository.Repository, entry repository.IndexEntry, tx *transaction.Transaction, db *installed.DB, install installOptions) (stagedPackage, error) {
+	localFile, checksum, cached, err := fetchArchive(log, repo, entry, install.cache)
+	if err != nil {
+		return stagedPackage{}, err
+	}
+
+	if err := install.policy.verify(log, repo, entry, checksum); err != nil {
+		if !cached {
+			os.Remove(localFile)
+		}
+		return stagedPackage{}, err
+	}
+
+	if !cached {
+		localFile, err = install.cache.Put(localFile, cache.Entry{
+			Checksum: checksum,
+			Name:     entry.Name,
+			Version:  entry.Version,
+			File:     entry.File,
+			Source:   entry.Source,
+		})
+		if err != nil {
+			log.Error("Ошибка сохранения архива в кэш", "файл", entry.File, "ошибка", err.Error())
+			return stagedPackage{}, err
+		}
+	}
+
+	stage, err := tx.StageDir(entry.Name)
+	if err != nil {
+		return stagedPackage{}, err
+	}
+	// директория распаковки повторяет в копии положение пакета в корне
+	dest := install.dests[entry.Name]
+	target := filepath.Join(stage, filepath.FromSlash(dest))
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return stagedPackage{}, err
+	}
+
+	log.Debug("Распаковка пакета", "файл", localFile, "формат", entry.Format, "цель", target)
+	switch entry.Format {
+	case "zip":
+		if err := archive.ExtractZipWithLimits(log, localFile, target, install.limits); err != nil {
+			log.Error("Ошибка распаковки ZIP архива", "файл", entry.File, "ошибка", err.Error())
+			return stagedPackage{}, errors.NewArchiveExtractionError(entry.File, install.root, err)
+		}
+	case "tar.gz":
+		if err := archive.ExtractTarGzWithLimits(log, localFile, target, install.limits); err != nil {
+			log.Error("Ошибка распаковки tar.gz архива", "файл", entry.File, "ошибка", err.Error())
+			return stagedPackage{}, errors.NewArchiveExtractionError(entry.File, install.root, err)
+		}
+	default:
+		log.Error("Неподдерживаемый формат архива", "формат", entry.Format, "файл", entry.File)
+		return stagedPackage{}, fmt.Errorf("неподдерживаемый формат архива: %s", entry.Format)
+	}
+
+	files, err := installedFiles(localFile, entry.Format, stage, dest, install.root, db)
+	if err != nil {
+		log.Error("Ошибка описания установленных файлов", "имя", entry.Name, "ошибка", err.Error())
+		return stagedPackage{}, err
+	}
+
+	return stagedPackage{
+		pkg: installed.Package{
+			Name:         entry.Name,
+			Version:      entry.Version,
+			Format:       entry.Format,
+			File:         entry.File,
+			Source:       entry.Source,
+			Checksum:     checksum,
+			Dependencies: entry.Dependencies,
+			Dest:         dest,
+			Installed:    time.Now().UTC(),
+			Files:        files,
+		},
+		stage: stage,
+	}, nil
+}
+
+// installedFiles описывает файлы архива по распакованной копии в dir.
+// Пути в описании отсчитываются от корня установки, то есть включают dest.
+func installedFiles(archivePath, format, dir, dest, root string, db *installed.DB) ([]installed.File, error) {
+	names, dirs, err := archive.Contents(archivePath, format)
+	if err != nil {
+		return nil, err
+	}
+	if dest != "" {
+		for i, name := range names {
+			names[i] = path.Join(dest, name)
+		}
+		for i, d := range dirs {
+			dirs[i] = path.Join(dest, d)
+		}
+	}
+
+	files, err := installed.Describe(dir, names)
+	if err != nil {
+		return nil, err
+	}
+	// созданные установкой директории записываются, чтобы pm remove удалил
+	// их, не трогая те, что были в корне до установки
+	created, err := db.CreatedDirs(root, names, dirs)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range created {
+		files = append(files, installed.File{Path: d, Dir: true})
+	}
+	return files, nil
+}
+
+// fetchArchive возвращает путь к архиву пакета и его контрольную сумму.
+// Архив с известной контрольной суммой берётся из кэша, иначе скачивается
+// во временный файл кэша и проверяется; cached сообщает, откуда он взят.
+func fetchArchive(log logger.LoggerInterface, repo repository.Repository, entry repository.IndexEntry, c *cache.Cache) (string, string, bool, error) {
+	if entry.Checksum != "" {
+		if path, ok := c.Get(entry.Checksum); ok {
+			log.Debug("Пакет найден в кэше", "файл", entry.File, "sha256", entry.Checksum)
+			return path, entry.Checksum, true, nil
+		}
+	}
+
+	localFile, err := c.TempFile(entry.File)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	log.Debug("Скачивание пакета", "файл", entry.File, "локальный_файл", localFile)
+	if err := repo.Download(entry.File, localFile); err != nil {
+		log.Error("Ошибка скачивания пакета", "имя", entry.Name, "файл", entry.File, "ошибка", err.Error())
+		os.Remove(localFile)
+		return "", "", false, err
+	}
+
+	checksum, err := verifyChecksum(log, repo, entry, localFile)
+	if err != nil {
+		os.Remove(localFile)
+		return "", "", false, err
+	}
+	return localFile, checksum, false, nil
+}
+
+// verifyChecksum сверяет скачанный архив с контрольной суммой из индекса
+// (или lock-файла), а если её там нет — с файлом .sha256 рядом с архивом.
+// Возвращает фактическую контрольную сумму архива.
+func verifyChecksum(log logger.LoggerInterface, repo repository.Repository, entry repository.IndexEntry, localFile string) (string, error) {
+	actual, _, err := utils.FileSHA256(localFile)
+	if err != nil {
+		return "", err
+	}
+
+	expected := entry.Checksum
+	if expected == "" {
+		expected, err = downloadChecksum(log, repo, entry, localFile)
+		if err != nil {
+			return "", err
+		}
+		if expected == "" {
+			log.Warn("Контрольная сумма пакета неизвестна, проверка целостности пропущена", "файл", entry.File)
+			return actual, nil
+		}
+	}
+
+	if actual != expected {
+		log.Error("Контрольная сумма не совпадает, установка отменена", "файл", entry.File, "ожидалась", expected, "получена", actual)
+		return "", errors.NewIntegrityError(entry.File, expected, actual)
+	}
+
+	log.Debug("Контрольная сумма совпадает", "файл", entry.File, "sha256", actual)
+	return actual, nil
+}
+
+func downloadChecksum(log logger.LoggerInterface, repo repository.Repository, entry repository.IndexEntry, localFile string) (string, error) {
+	checksumFile := entry.File + utils.ChecksumExtension
+	localChecksum := localFile + utils.ChecksumExtension
+	defer os.Remove(localChecksum)
+
+	if err := repo.Download(checksumFile, localChecksum); err != nil {
+		if stderrors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		log.Error("Ошибка скачивания контрольной суммы", "файл", checksumFile, "ошибка", err.Error())
+		return "", err
+	}
+
+	data, err := os.ReadFile(localChecksum)
+	if err != nil {
+		return "", err
+	}
+	checksum, err := utils.ParseChecksumFile(data)
+	if err != nil {
+		log.Error("Некорректный файл контрольной суммы", "файл", checksumFile, "ошибка", err.Error())
+		return "", err
+	}
+	return checksum, nil
+}
+
+func loadIndex(log logger.LoggerInterface, repo repository.Repository) (*repository.Index, error) {
+	log.Debug("Чтение индекса репозитория", "репозиторий", repo.URL())
+	idx, err := repository.LoadIndex(repo)
+	if err == nil {
+		log.Debug("Индекс репозитория прочитан", "пакетов", len(idx.Packages))
+		idx.SetSource(repo.URL())
+		return idx, nil
+	}
+	if !stderrors.Is(err, errors.ErrIndexNotFound) {
+		log.Error("Ошибка чтения индекса репозитория", "репозиторий", repo.URL(), "ошибка", err.Error())
+		return nil, err
+	}
+
+	log.Warn("Индекс репозитория не найден, используется список файлов. Выполните pm reindex", "репозиторий", repo.URL())
+	files, err := repo.List()
+	if err != nil {
+		log.Error("Ошибка чтения содержимого репозитория", "репозиторий", repo.URL(), "ошибка", err.Error())
+		return nil, err
+	}
+	log.Debug("Найдено файлов в репозитории", "количество", len(files), "репозиторий", repo.URL())
+
+	idx = repository.IndexFromFiles(files)
+	idx.SetSource(repo.URL())
+	return idx, nil
+}
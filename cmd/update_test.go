@@ -0,0 +1,463 @@
+package main
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pm/config"
+	"pm/internal/archive"
+	"pm/internal/cache"
+	"pm/internal/errors"
+	"pm/internal/installed"
+	"pm/internal/lock"
+	"pm/internal/logger"
+	"pm/internal/repository"
+	"pm/internal/signing"
+	"pm/internal/utils"
+)
+
+// testRepository — локальный репозиторий с архивами пакетов для тестов
+// установки.
+type testRepository struct {
+	t    *testing.T
+	dir  string
+	repo repository.Repository
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := repository.Open("file://"+dir, repository.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testRepository{t: t, dir: dir, repo: repo}
+}
+
+// publish упаковывает files (путь — содержимое) в zip и возвращает запись
+// индекса для него.
+func (r *testRepository) publish(name, ver string, deps []repository.Dependency, files map[string]string) repository.IndexEntry {
+	r.t.Helper()
+	src := r.t.TempDir()
+	var paths []string
+	for rel, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	file := name + "-" + ver + ".zip"
+	out := filepath.Join(r.dir, file)
+	if err := archive.CreateZipWithRoot(logger.NewBaseLogger(), paths, out, src); err != nil {
+		r.t.Fatal(err)
+	}
+	checksum, _, err := utils.FileSHA256(out)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return repository.IndexEntry{Name: name, Version: ver, Format: "zip", File: file, Checksum: checksum, Dependencies: deps, Source: r.repo.URL()}
+}
+
+// newInstallOptions возвращает параметры установки в root без подписей и
+// с кэшем во временной директории.
+func newInstallOptions(t *testing.T, root string, constraints map[string][]string) installOptions {
+	t.Helper()
+	trusted, err := signing.LoadTrustedKeys(filepath.Join(t.TempDir(), "trusted_keys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := cache.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return installOptions{
+		policy:      &signaturePolicy{trusted: trusted},
+		limits:      archive.DefaultLimits,
+		cache:       c,
+		root:        root,
+		dests:       make(map[string]string),
+		constraints: constraints,
+	}
+}
+
+func installedVersions(t *testing.T, root string) map[string]string {
+	t.Helper()
+	db, err := installed.Load(installed.PathFor(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := make(map[string]string)
+	for _, p := range db.Packages {
+		versions[p.Name] = p.Version
+	}
+	return versions
+}
+
+func entryVersions(entries []repository.IndexEntry) map[string]string {
+	versions := make(map[string]string)
+	for _, e := range entries {
+		versions[e.Name] = e.Version
+	}
+	return versions
+}
+
+func TestKeepInstalled(t *testing.T) {
+	pkgs := &config.Packages{Packages: []config.Packet{{Name: "app", Ver: ">=1.0"}}}
+	entries := []repository.IndexEntry{
+		{Name: "app", Version: "1.2.0", Dependencies: []repository.Dependency{{Name: "lib", Ver: "<2.0"}}},
+		{Name: "lib", Version: "1.0.0"},
+	}
+	constraints := versionConstraints(pkgs, entries)
+
+	tests := []struct {
+		name        string
+		installed   installed.Package
+		selected    repository.IndexEntry
+		dest        string
+		constraints map[string][]string
+		want        bool
+	}{
+		{
+			name:        "установлена версия новее выбранной: не понижается",
+			installed:   installed.Package{Name: "app", Version: "1.7.0"},
+			selected:    repository.IndexEntry{Name: "app", Version: "1.2.0"},
+			constraints: constraints,
+			want:        true,
+		},
+		{
+			name:        "установлена версия новее, чем есть в репозитории",
+			installed:   installed.Package{Name: "lib", Version: "1.9.0"},
+			selected:    repository.IndexEntry{Name: "lib", Version: "1.0.0"},
+			constraints: constraints,
+			want:        true,
+		},
+		{
+			name:        "выбрана более новая версия: обновляется",
+			installed:   installed.Package{Name: "app", Version: "1.0.0"},
+			selected:    repository.IndexEntry{Name: "app", Version: "1.2.0"},
+			constraints: constraints,
+			want:        false,
+		},
+		{
+			name:        "установленная версия не удовлетворяет условию packages.json",
+			installed:   installed.Package{Name: "app", Version: "0.9.0"},
+			selected:    repository.IndexEntry{Name: "app", Version: "0.8.0"},
+			constraints: constraints,
+			want:        false,
+		},
+		{
+			name:        "установленная версия не удовлетворяет зависимости",
+			installed:   installed.Package{Name: "lib", Version: "2.1.0"},
+			selected:    repository.IndexEntry{Name: "lib", Version: "1.0.0"},
+			constraints: constraints,
+			want:        false,
+		},
+		{
+			name:        "установка по lock-файлу: ставится зафиксированная версия",
+			installed:   installed.Package{Name: "app", Version: "1.7.0"},
+			selected:    repository.IndexEntry{Name: "app", Version: "1.2.0"},
+			constraints: nil,
+			want:        false,
+		},
+		{
+			name:        "другая директория установки",
+			installed:   installed.Package{Name: "app", Version: "1.7.0", Dest: "opt"},
+			selected:    repository.IndexEntry{Name: "app", Version: "1.2.0"},
+			constraints: constraints,
+			want:        false,
+		},
+		{
+			name:        "та же версия с другой контрольной суммой",
+			installed:   installed.Package{Name: "app", Version: "1.2.0", Checksum: "aaa"},
+			selected:    repository.IndexEntry{Name: "app", Version: "1.2.0", Checksum: "bbb"},
+			constraints: constraints,
+			want:        false,
+		},
+		{
+			name:        "некорректная установленная версия",
+			installed:   installed.Package{Name: "app", Version: "latest"},
+			selected:    repository.IndexEntry{Name: "app", Version: "1.2.0"},
+			constraints: constraints,
+			want:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := keepInstalled(tt.installed, tt.selected, tt.dest, tt.constraints)
+			if got != tt.want {
+				t.Errorf("keepInstalled() = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestInstallPackagesKeepInstalled проверяет, что оставленная установленная
+// версия не расходится со своими зависимостями: при --prefer-lowest app 2.0
+// остаётся только вместе с нужной ему lib 2.0.
+func TestInstallPackagesKeepInstalled(t *testing.T) {
+	r := newTestRepository(t)
+	app1 := r.publish("app", "1.0.0", []repository.Dependency{{Name: "lib", Ver: "<2.0"}}, map[string]string{"bin/app": "app 1.0"})
+	app2 := r.publish("app", "2.0.0", []repository.Dependency{{Name: "lib", Ver: ">=2.0"}}, map[string]string{"bin/app": "app 2.0"})
+	lib1 := r.publish("lib", "1.0.0", nil, map[string]string{"lib/lib.so": "lib 1.0"})
+	lib2 := r.publish("lib", "2.0.0", nil, map[string]string{"lib/lib.so": "lib 2.0"})
+	tool := r.publish("tool", "1.0.0", []repository.Dependency{{Name: "lib", Ver: "<2.0"}}, map[string]string{"bin/tool": "tool"})
+	repos := map[string]repository.Repository{r.repo.URL(): r.repo}
+	log := logger.NewBaseLogger()
+
+	tests := []struct {
+		name string
+		pkgs *config.Packages
+		// selected — выбор резолвера при --prefer-lowest
+		selected []repository.IndexEntry
+		want     map[string]string
+	}{
+		{
+			name:     "зависимость оставленной версии тоже остаётся",
+			pkgs:     &config.Packages{Packages: []config.Packet{{Name: "app", Ver: ">=1.0"}}},
+			selected: []repository.IndexEntry{app1, lib1},
+			want:     map[string]string{"app": "2.0.0", "lib": "2.0.0"},
+		},
+		{
+			name:     "зависимость заменяется: оставленная версия понижается вместе с ней",
+			pkgs:     &config.Packages{Packages: []config.Packet{{Name: "app", Ver: ">=1.0"}, {Name: "tool"}}},
+			selected: []repository.IndexEntry{app1, lib1, tool},
+			want:     map[string]string{"app": "1.0.0", "lib": "1.0.0", "tool": "1.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if _, err := installPackages(log, repos, []repository.IndexEntry{app2, lib2}, newInstallOptions(t, root, nil)); err != nil {
+				t.Fatalf("Ошибка первой установки: %v", err)
+			}
+
+			entries, err := installPackages(log, repos, tt.selected, newInstallOptions(t, root, versionConstraints(tt.pkgs, nil)))
+			if err != nil {
+				t.Fatalf("Ошибка installPackages: %v", err)
+			}
+			if got := entryVersions(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Версии для lock-файла = %v, ожидалось %v", got, tt.want)
+			}
+			if got := installedVersions(t, root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Установленные версии = %v, ожидалось %v", got, tt.want)
+			}
+
+			// выбранный набор должен пройти проверку lock-файла
+			l := lock.New(tt.pkgs, entries)
+			if err := l.Check(tt.pkgs); err != nil {
+				t.Errorf("Lock-файл не проходит проверку: %v", err)
+			}
+		})
+	}
+}
+
+func TestInstallPackagesDest(t *testing.T) {
+	r := newTestRepository(t)
+	app := r.publish("app", "1.0.0", nil, map[string]string{"bin/app": "app", "etc/app.conf": "conf"})
+	lib := r.publish("lib", "1.0.0", nil, map[string]string{"lib/lib.so": "lib"})
+	repos := map[string]repository.Repository{r.repo.URL(): r.repo}
+	log := logger.NewBaseLogger()
+
+	// установка не должна ничего писать в текущую директорию
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+	root := filepath.Join(t.TempDir(), "prefix")
+
+	tests := []struct {
+		name    string
+		dest    string
+		present []string
+		missing []string
+	}{
+		{
+			name:    "dest пакета внутри корня",
+			dest:    "opt/app",
+			present: []string{"opt/app/bin/app", "opt/app/etc/app.conf", "lib/lib.so"},
+			missing: []string{"bin/app", "etc/app.conf"},
+		},
+		{
+			name:    "смена dest переносит файлы",
+			dest:    "usr/local",
+			present: []string{"usr/local/bin/app", "usr/local/etc/app.conf", "lib/lib.so"},
+			missing: []string{"opt/app/bin/app", "opt/app/etc/app.conf", "opt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			install := newInstallOptions(t, root, nil)
+			install.dests["app"] = tt.dest
+			if _, err := installPackages(log, repos, []repository.IndexEntry{app, lib}, install); err != nil {
+				t.Fatalf("Ошибка installPackages: %v", err)
+			}
+
+			for _, rel := range tt.present {
+				if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel))); err != nil {
+					t.Errorf("Файл %s не установлен: %v", rel, err)
+				}
+			}
+			for _, rel := range tt.missing {
+				if _, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel))); !os.IsNotExist(err) {
+					t.Errorf("Файл %s не должен существовать: %v", rel, err)
+				}
+			}
+
+			db, err := installed.Load(installed.PathFor(root))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkg, ok := db.Find("app")
+			if !ok {
+				t.Fatal("Пакет app не записан в базу установленных")
+			}
+			if pkg.Dest != tt.dest {
+				t.Errorf("Dest = %q, ожидалось %q", pkg.Dest, tt.dest)
+			}
+			for _, f := range pkg.Files {
+				if !f.Dir && !strings.HasPrefix(f.Path, tt.dest+"/") {
+					t.Errorf("Путь %s в базе не включает dest %s", f.Path, tt.dest)
+				}
+			}
+
+			if names, err := os.ReadDir(cwd); err != nil || len(names) != 0 {
+				t.Errorf("В текущей директории появились файлы: %v, %v", names, err)
+			}
+		})
+	}
+}
+
+func TestInstallPackagesCreatedDirs(t *testing.T) {
+	r := newTestRepository(t)
+	app := r.publish("app", "1.0.0", nil, map[string]string{"bin/app": "app", "etc/app.conf": "conf"})
+	tool := r.publish("tool", "1.0.0", nil, map[string]string{"bin/tool": "tool"})
+	repos := map[string]repository.Repository{r.repo.URL(): r.repo}
+	log := logger.NewBaseLogger()
+
+	root := t.TempDir()
+	// пустая etc создана пользователем до установки
+	if err := os.Mkdir(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := installPackages(log, repos, []repository.IndexEntry{app}, newInstallOptions(t, root, nil)); err != nil {
+		t.Fatalf("Ошибка установки app: %v", err)
+	}
+	// tool ставится в bin, созданную для app
+	if _, err := installPackages(log, repos, []repository.IndexEntry{app, tool}, newInstallOptions(t, root, nil)); err != nil {
+		t.Fatalf("Ошибка установки tool: %v", err)
+	}
+
+	exists := func(rel string) bool {
+		_, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel)))
+		return err == nil
+	}
+	uninstall := func(name string) {
+		t.Helper()
+		db, err := installed.Load(installed.PathFor(root))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := installed.Uninstall(root, db, name); err != nil {
+			t.Fatalf("Ошибка удаления %s: %v", name, err)
+		}
+		if err := installed.Save(installed.PathFor(root), db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uninstall("app")
+	if exists("etc/app.conf") || exists("bin/app") {
+		t.Error("Файлы app не удалены")
+	}
+	if !exists("etc") {
+		t.Error("Директория etc, созданная до установки, удалена")
+	}
+	if !exists("bin/tool") {
+		t.Error("Файл tool удалён вместе с app")
+	}
+
+	uninstall("tool")
+	if exists("bin") {
+		t.Error("Директория bin, созданная установкой, не удалена после последнего пакета")
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	r := newTestRepository(t)
+	entry := r.publish("app", "1.0.0", nil, map[string]string{"bin/app": "app"})
+	actual := entry.Checksum
+	other := strings.Repeat("0", len(actual))
+	sidecar := entry.File + utils.ChecksumExtension
+
+	tests := []struct {
+		name string
+		// indexed — контрольная сумма из индекса; sidecar — содержимое
+		// файла .sha256 рядом с архивом, пустая строка — файла нет
+		indexed       string
+		sidecar       string
+		wantIntegrity bool
+		wantErr       bool
+	}{
+		{name: "сумма из индекса совпадает", indexed: actual},
+		{name: "сумма из индекса не совпадает", indexed: other, wantIntegrity: true},
+		{name: "сумма из индекса важнее файла .sha256", indexed: actual, sidecar: utils.FormatChecksumFile(other, entry.File)},
+		{name: "файл .sha256 совпадает", sidecar: utils.FormatChecksumFile(actual, entry.File)},
+		{name: "файл .sha256 не совпадает", sidecar: utils.FormatChecksumFile(other, entry.File), wantIntegrity: true},
+		{name: "некорректный файл .sha256", sidecar: "не сумма\n", wantErr: true},
+		{name: "нет ни суммы, ни файла .sha256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(filepath.Join(r.dir, sidecar))
+			if tt.sidecar != "" {
+				if err := os.WriteFile(filepath.Join(r.dir, sidecar), []byte(tt.sidecar), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			local := filepath.Join(t.TempDir(), entry.File)
+			if err := r.repo.Download(entry.File, local); err != nil {
+				t.Fatal(err)
+			}
+			e := entry
+			e.Checksum = tt.indexed
+
+			got, err := verifyChecksum(logger.NewBaseLogger(), r.repo, e, local)
+			var integrity *errors.IntegrityError
+			switch {
+			case tt.wantIntegrity:
+				if !stderrors.As(err, &integrity) {
+					t.Fatalf("Ожидалась IntegrityError, получено: %v", err)
+				}
+				if integrity.Actual != actual || integrity.Expected != other {
+					t.Errorf("IntegrityError = %+v", integrity)
+				}
+			case tt.wantErr:
+				if err == nil || stderrors.As(err, &integrity) {
+					t.Fatalf("Ожидалась ошибка разбора файла .sha256, получено: %v", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Неожиданная ошибка: %v", err)
+				}
+				if got != actual {
+					t.Errorf("verifyChecksum() = %s, ожидалась %s", got, actual)
+				}
+			}
+			if _, err := os.Stat(local + utils.ChecksumExtension); !os.IsNotExist(err) {
+				t.Error("Скачанный файл .sha256 не удалён")
+			}
+		})
+	}
+}
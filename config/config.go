@@ -2,43 +2,198 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
+// Patterns — список шаблонов, который в конфигурации можно записать
+// и одной строкой, и массивом строк.
+type Patterns []string
+
+func (p *Patterns) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*p = singlePattern(single)
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("ожидалась строка или массив строк")
+	}
+	*p = list
+	return nil
+}
+
+func (p *Patterns) UnmarshalYAML(value *yaml.Node) error {
+	var single string
+	if err := value.Decode(&single); err == nil {
+		*p = singlePattern(single)
+		return nil
+	}
+
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return fmt.Errorf("ожидалась строка или массив строк")
+	}
+	*p = list
+	return nil
+}
+
+func singlePattern(s string) Patterns {
+	if s == "" {
+		return nil
+	}
+	return Patterns{s}
+}
+
 type Target struct {
-	Path    string `json:"path" yaml:"path"`
-	Exclude string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	Path    string   `json:"path" yaml:"path"`
+	Exclude Patterns `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+
+	// EmptyDirs добавляет в архив пустые директории отдельными записями.
+	EmptyDirs bool `json:"empty_dirs,omitempty" yaml:"empty_dirs,omitempty"`
+
+	// Dest — директория в архиве (а значит, и при установке), в которую
+	// попадают файлы цели, например bin/ или etc/app/. Пути сохраняются
+	// относительно неизменной части шаблона path.
+	Dest string `json:"dest,omitempty" yaml:"dest,omitempty"`
+}
+
+// UnmarshalJSON принимает цель и строкой с шаблоном пути, и объектом
+// {"path": ..., "exclude": ...}.
+func (t *Target) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*t = Target{Path: path}
+		return nil
+	}
+
+	type plain Target
+	var target plain
+	if err := json.Unmarshal(data, &target); err != nil {
+		return fmt.Errorf("цель должна быть строкой или объектом с полем path: %v", err)
+	}
+	*t = Target(target)
+	return nil
+}
+
+func (t *Target) UnmarshalYAML(value *yaml.Node) error {
+	var path string
+	if err := value.Decode(&path); err == nil {
+		*t = Target{Path: path}
+		return nil
+	}
+
+	type plain Target
+	var target plain
+	if err := value.Decode(&target); err != nil {
+		return fmt.Errorf("цель должна быть строкой или объектом с полем path: %v", err)
+	}
+	*t = Target(target)
+	return nil
 }
 
 type Packet struct {
-	Name    string   `json:"name" yaml:"name"`
-	Ver     string   `json:"ver" yaml:"ver"`
-	Format  string   `json:"format,omitempty" yaml:"format,omitempty"`
-	Targets []Target `json:"targets" yaml:"targets"`
-	Packets []Packet `json:"packets,omitempty" yaml:"packets,omitempty"`
+	Name string `json:"name" yaml:"name"`
+	Ver  string `json:"ver" yaml:"ver"`
+	// Repository закрепляет пакет из packages.json за репозиторием с этим
+	// именем.
+	Repository string   `json:"repository,omitempty" yaml:"repository,omitempty"`
+	Format     string   `json:"format,omitempty" yaml:"format,omitempty"`
+	Root       string   `json:"root,omitempty" yaml:"root,omitempty"`
+	Targets    []Target `json:"targets" yaml:"targets"`
+	Packets    []Packet `json:"packets,omitempty" yaml:"packets,omitempty"`
+
+	// Dest — директория относительно корня установки, в которую
+	// распаковывается пакет из packages.json.
+	Dest string `json:"dest,omitempty" yaml:"dest,omitempty"`
+}
+
+// Repository — репозиторий, объявленный в packages.json. Без url ссылается
+// на одноимённый репозиторий из настроек pm и может переопределить его
+// приоритет.
+type Repository struct {
+	Name     string `json:"name" yaml:"name"`
+	URL      string `json:"url,omitempty" yaml:"url,omitempty"`
+	Priority int    `json:"priority,omitempty" yaml:"priority,omitempty"`
 }
 
 type Packages struct {
-	Packages []Packet `json:"packages" yaml:"packages"`
+	Repositories []Repository `json:"repositories,omitempty" yaml:"repositories,omitempty"`
+	Packages     []Packet     `json:"packages" yaml:"packages"`
 }
 
-func LoadPacketConfig(path string) (*Packet, error) {
+func isYAML(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// source — разобранный файл конфигурации: позиции полей для сообщений
+// проверки и декодер значений из того же разбора.
+type source struct {
+	data   []byte
+	doc    *document
+	decode func(v interface{}) error
+}
+
+// parseSource разбирает файл один раз: YAML декодируется из того же дерева
+// узлов, по которому строятся позиции.
+func parseSource(path string, data []byte) (*source, error) {
+	if !isYAML(path) {
+		doc, err := jsonDocument(data)
+		if err != nil {
+			return nil, err
+		}
+		decode := func(v interface{}) error { return json.Unmarshal(data, v) }
+		return &source{data: data, doc: doc, decode: decode}, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	decode := func(v interface{}) error {
+		// Пустой файл даёт пустое дерево, значение остаётся нулевым
+		if root.Kind == 0 {
+			return nil
+		}
+		return root.Decode(v)
+	}
+	return &source{data: data, doc: yamlDocument(&root), decode: decode}, nil
+}
+
+func readSource(path string) (*source, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	src, err := parseSource(path, data)
+	if err != nil {
+		return nil, decodeError(path, data, err)
+	}
+	return src, nil
+}
 
+// LoadPacketConfig читает и проверяет packet.json/packet.yaml. Все найденные
+// проблемы возвращаются разом в errors.ConfigValidationError.
+func LoadPacketConfig(path string) (*Packet, error) {
+	src, err := readSource(path)
+	if err != nil {
+		return nil, err
+	}
+	return loadPacket(path, src)
+}
+
+func loadPacket(path string, src *source) (*Packet, error) {
 	var packet Packet
-	ext := filepath.Ext(path)
-	if ext == ".yaml" || ext == ".yml" {
-		err = yaml.Unmarshal(data, &packet)
-	} else {
-		err = json.Unmarshal(data, &packet)
+	if err := src.decode(&packet); err != nil {
+		return nil, decodeError(path, src.data, err)
 	}
-	if err != nil {
+	if err := validatePacket(path, src.doc, &packet); err != nil {
 		return nil, err
 	}
 
@@ -46,21 +201,37 @@ func LoadPacketConfig(path string) (*Packet, error) {
 }
 
 func LoadPackagesConfig(path string) (*Packages, error) {
-	data, err := os.ReadFile(path)
+	src, err := readSource(path)
 	if err != nil {
 		return nil, err
 	}
+	return loadPackages(path, src)
+}
 
+func loadPackages(path string, src *source) (*Packages, error) {
 	var pkgs Packages
-	ext := filepath.Ext(path)
-	if ext == ".yaml" || ext == ".yml" {
-		err = yaml.Unmarshal(data, &pkgs)
-	} else {
-		err = json.Unmarshal(data, &pkgs)
+	if err := src.decode(&pkgs); err != nil {
+		return nil, decodeError(path, src.data, err)
 	}
-	if err != nil {
+	if err := validatePackages(path, src.doc, &pkgs); err != nil {
 		return nil, err
 	}
 
 	return &pkgs, nil
 }
+
+// Lint проверяет конфигурацию, определяя её вид по содержимому: файл с
+// полем packages считается packages.json, остальные — packet.json.
+func Lint(path string) error {
+	src, err := readSource(path)
+	if err != nil {
+		return err
+	}
+
+	if contains(src.doc.keys[""], "packages") {
+		_, err = loadPackages(path, src)
+	} else {
+		_, err = loadPacket(path, src)
+	}
+	return err
+}
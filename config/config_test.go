@@ -0,0 +1,300 @@
+package config
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pm/internal/errors"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPacketConfigTargets(t *testing.T) {
+	want := []Target{
+		{Path: "./test_data/*.txt"},
+		{Path: "./test_data/*.log", Exclude: Patterns{"*.tmp"}},
+		{Path: "./src", Exclude: Patterns{"*.tmp", "build/**"}, EmptyDirs: true},
+	}
+
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		want        []Target
+		expectError bool
+	}{
+		{
+			name: "JSON: строки и объекты вперемешку",
+			file: "packet.json",
+			content: `{
+  "name": "app",
+  "ver": "1.0",
+  "targets": [
+    "./test_data/*.txt",
+    { "path": "./test_data/*.log", "exclude": "*.tmp" },
+    { "path": "./src", "exclude": ["*.tmp", "build/**"], "empty_dirs": true }
+  ]
+}`,
+			want: want,
+		},
+		{
+			name: "YAML: строки и объекты вперемешку",
+			file: "packet.yaml",
+			content: `name: app
+ver: "1.0"
+targets:
+  - ./test_data/*.txt
+  - path: ./test_data/*.log
+    exclude: "*.tmp"
+  - path: ./src
+    exclude: ["*.tmp", "build/**"]
+    empty_dirs: true
+`,
+			want: want,
+		},
+		{
+			name:        "JSON: цель неверного типа",
+			file:        "packet.json",
+			content:     `{"name": "app", "ver": "1.0", "targets": [42]}`,
+			expectError: true,
+		},
+		{
+			name:        "JSON: исключения неверного типа",
+			file:        "packet.json",
+			content:     `{"name": "app", "ver": "1.0", "targets": [{"path": "a", "exclude": {"x": 1}}]}`,
+			expectError: true,
+		},
+		{
+			name: "YAML: цель неверного типа",
+			file: "packet.yml",
+			content: `name: app
+ver: "1.0"
+targets:
+  - [a, b]
+`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet, err := LoadPacketConfig(writeConfig(t, tt.file, tt.content))
+
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Ожидалась ошибка, но её не было")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Не ожидалась ошибка: %v", err)
+			}
+			if !reflect.DeepEqual(packet.Targets, tt.want) {
+				t.Errorf("Цели не совпадают.\nОжидалось: %+v\nПолучено: %+v", tt.want, packet.Targets)
+			}
+		})
+	}
+}
+
+func TestLoadPackagesConfig(t *testing.T) {
+	want := []Packet{
+		{Name: "app", Ver: ">=1.0", Format: "zip", Targets: []Target{{Path: "bin/*"}}, Dest: "opt/app"},
+		{Name: "lib", Ver: "1.2", Repository: "main", Packets: []Packet{{Name: "base", Ver: "1.0"}}},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "JSON: поля пакета",
+			file: "packages.json",
+			content: `{
+  "packages": [
+    {"name": "app", "ver": ">=1.0", "format": "zip", "targets": ["bin/*"], "dest": "opt/app"},
+    {"name": "lib", "ver": "1.2", "repository": "main", "packets": [{"name": "base", "ver": "1.0"}]}
+  ]
+}`,
+		},
+		{
+			name: "YAML: поля пакета",
+			file: "packages.yaml",
+			content: `packages:
+  - name: app
+    ver: ">=1.0"
+    format: zip
+    targets: [bin/*]
+    dest: opt/app
+  - name: lib
+    ver: "1.2"
+    repository: main
+    packets:
+      - name: base
+        ver: "1.0"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkgs, err := LoadPackagesConfig(writeConfig(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("Не ожидалась ошибка: %v", err)
+			}
+			if !reflect.DeepEqual(pkgs.Packages, want) {
+				t.Errorf("Пакеты не совпадают.\nОжидалось: %+v\nПолучено: %+v", want, pkgs.Packages)
+			}
+		})
+	}
+}
+
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		load    func(string) error
+		want    []string
+	}{
+		{
+			name: "packet.json: все проблемы с позициями",
+			file: "packet.json",
+			content: `{
+  "name": "my app",
+  "ver": "1.x",
+  "format": "rar",
+  "targets": [
+    { "path": "./a", "exlude": "*.tmp" }
+  ],
+  "packets": [
+    { "name": "utils", "ver": ">>1.0" }
+  ]
+}`,
+			load: func(path string) error { _, err := LoadPacketConfig(path); return err },
+			want: []string{
+				`2:3: name: некорректное имя пакета "my app"`,
+				`3:3: ver: некорректная версия "1.x"`,
+				`4:3: format: неизвестный формат "rar"`,
+				`6:22: targets[0].exlude: неизвестное поле "exlude"`,
+				`9:24: packets[0].ver: некорректное условие версии ">>1.0"`,
+			},
+		},
+		{
+			name: "packet.yaml: пропущенные поля",
+			file: "packet.yaml",
+			content: `name: app
+targets: []
+extra: 1
+`,
+			load: func(path string) error { _, err := LoadPacketConfig(path); return err },
+			want: []string{
+				`1:1: ver: версия пакета не задана`,
+				`2:1: targets: не задано ни одной цели`,
+				`3:1: extra: неизвестное поле "extra"`,
+			},
+		},
+		{
+			name: "packages.yaml: повтор и пустое имя",
+			file: "packages.yaml",
+			content: `packages:
+  - name: app
+  - name: app
+    ver: ">=1.0"
+  - ver: "1.0"
+`,
+			load: func(path string) error { _, err := LoadPackagesConfig(path); return err },
+			want: []string{
+				`3:5: packages[1].name: пакет "app" указан несколько раз`,
+				`5:5: packages[2].name: имя пакета не задано`,
+			},
+		},
+		{
+			name: "packages.yaml: репозитории",
+			file: "packages.yaml",
+			content: `repositories:
+  - name: internal
+    url: sftp://pkg.example.com/srv/pm
+  - name: internal
+  - url: file:///srv/pm
+    priority: -1
+packages:
+  - name: app
+    repository: "bad name"
+`,
+			load: func(path string) error { _, err := LoadPackagesConfig(path); return err },
+			want: []string{
+				`4:5: repositories[1].name: репозиторий "internal" указан несколько раз`,
+				`5:5: repositories[2].name: имя репозитория не задано`,
+				`6:5: repositories[2].priority: приоритет должен быть положительным`,
+				`9:5: packages[0].repository: некорректное имя репозитория "bad name"`,
+			},
+		},
+		{
+			name: "пути назначения",
+			file: "packages.yaml",
+			content: `packages:
+  - name: app
+    dest: opt/app
+  - name: lib
+    dest: ../lib
+  - name: tool
+    dest: /usr/local
+`,
+			load: func(path string) error { _, err := LoadPackagesConfig(path); return err },
+			want: []string{
+				`5:5: packages[1].dest: некорректный путь назначения "../lib"`,
+				`7:5: packages[2].dest: некорректный путь назначения "/usr/local"`,
+			},
+		},
+		{
+			name:    "синтаксическая ошибка JSON",
+			file:    "packages.json",
+			content: "{\n  \"packages\": [\n    { \"name\": \"app\", }\n  ]\n}",
+			load:    Lint,
+			want:    []string{`3:21: синтаксическая ошибка`},
+		},
+		{
+			name:    "lint определяет packages.json",
+			file:    "deps.json",
+			content: `{"packages": [{"name": "app", "ver": "1.0", "path": "bin"}]}`,
+			load:    Lint,
+			want:    []string{`1:45: packages[0].path: неизвестное поле "path"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.load(writeConfig(t, tt.file, tt.content))
+
+			var validationErr *errors.ConfigValidationError
+			if !stderrors.As(err, &validationErr) {
+				t.Fatalf("Ожидалась ошибка ConfigValidationError, получено: %v", err)
+			}
+
+			var got []string
+			for _, p := range validationErr.Problems {
+				got = append(got, p.String())
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Количество проблем не совпадает.\nОжидалось: %v\nПолучено: %v", tt.want, got)
+			}
+			for i := range tt.want {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("Проблема %d не совпадает.\nОжидалось начало: %s\nПолучено: %s", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
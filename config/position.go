@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+type position struct {
+	line   int
+	column int
+}
+
+// document хранит позиции полей исходного файла, чтобы ошибки проверки
+// указывали на строку. Пути полей записываются как "targets[1].path".
+type document struct {
+	positions map[string]position
+	keys      map[string][]string
+}
+
+func newDocument() *document {
+	return &document{
+		positions: map[string]position{},
+		keys:      map[string][]string{},
+	}
+}
+
+// position возвращает позицию поля, а если его в файле нет — позицию
+// ближайшего родителя.
+func (d *document) position(path string) position {
+	for {
+		if pos, ok := d.positions[path]; ok {
+			return pos
+		}
+		if path == "" {
+			return position{}
+		}
+		path = parentPath(path)
+	}
+}
+
+func (d *document) set(path string, pos position) {
+	if _, ok := d.positions[path]; !ok {
+		d.positions[path] = pos
+	}
+}
+
+func fieldPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func itemPath(parent string, i int) string {
+	return fmt.Sprintf("%s[%d]", parent, i)
+}
+
+func parentPath(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '.' || path[i] == '[' {
+			return path[:i]
+		}
+	}
+	return ""
+}
+
+func yamlDocument(root *yamlv3.Node) *document {
+	doc := newDocument()
+	var walk func(n *yamlv3.Node, path string)
+	walk = func(n *yamlv3.Node, path string) {
+		doc.set(path, position{n.Line, n.Column})
+		switch n.Kind {
+		case yamlv3.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, path)
+			}
+		case yamlv3.MappingNode:
+			doc.keys[path] = []string{}
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key, value := n.Content[i], n.Content[i+1]
+				child := fieldPath(path, key.Value)
+				doc.keys[path] = append(doc.keys[path], key.Value)
+				doc.set(child, position{key.Line, key.Column})
+				walk(value, child)
+			}
+		case yamlv3.SequenceNode:
+			for i, c := range n.Content {
+				walk(c, itemPath(path, i))
+			}
+		}
+	}
+	walk(root, "")
+
+	return doc
+}
+
+func jsonDocument(data []byte) (*document, error) {
+	doc := newDocument()
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// start возвращает позицию начала следующего токена
+	start := func() position {
+		off := int(dec.InputOffset())
+		for off < len(data) && bytes.IndexByte([]byte(" \t\r\n:,"), data[off]) >= 0 {
+			off++
+		}
+		return offsetPosition(data, off)
+	}
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		pos := start()
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		doc.set(path, pos)
+
+		switch tok {
+		case json.Delim('{'):
+			doc.keys[path] = []string{}
+			for dec.More() {
+				keyPos := start()
+				keyTok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := keyTok.(string)
+				child := fieldPath(path, key)
+				doc.keys[path] = append(doc.keys[path], key)
+				doc.set(child, keyPos)
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+			return err
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(itemPath(path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+			return err
+		}
+		return nil
+	}
+
+	if err := walk(""); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func offsetPosition(data []byte, offset int) position {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	column := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return position{line, column}
+}
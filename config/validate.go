@@ -0,0 +1,239 @@
+package config
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"pm/internal/errors"
+	"pm/pkg/version"
+)
+
+var (
+	packetFields     = []string{"name", "ver", "format", "root", "targets", "packets"}
+	targetFields     = []string{"path", "exclude", "empty_dirs", "dest"}
+	dependencyFields = []string{"name", "ver"}
+	packageFields    = []string{"name", "ver", "repository", "format", "targets", "packets", "dest"}
+	packagesFields   = []string{"repositories", "packages"}
+	repositoryFields = []string{"name", "url", "priority"}
+
+	formats = []string{"zip", "tar.gz", "tgz"}
+
+	namePattern           = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	repositoryNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+type validator struct {
+	doc      *document
+	problems []errors.ConfigProblem
+}
+
+func (v *validator) add(path, format string, args ...interface{}) {
+	pos := v.doc.position(path)
+	v.problems = append(v.problems, errors.ConfigProblem{
+		Line:    pos.line,
+		Column:  pos.column,
+		Field:   path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) fields(path string, allowed []string) {
+	for _, key := range v.doc.keys[path] {
+		if !contains(allowed, key) {
+			v.add(fieldPath(path, key), "неизвестное поле %q, допустимы: %s", key, strings.Join(allowed, ", "))
+		}
+	}
+}
+
+func (v *validator) name(path, name string) {
+	switch {
+	case name == "":
+		v.add(path, "имя пакета не задано")
+	case !namePattern.MatchString(name):
+		v.add(path, "некорректное имя пакета %q: допустимы буквы, цифры, '.', '_' и '-'", name)
+	}
+}
+
+func (v *validator) constraint(path, constraint string) {
+	if strings.TrimSpace(constraint) == "" {
+		return
+	}
+	if _, err := version.ParseConstraint(constraint); err != nil {
+		v.add(path, "некорректное условие версии %q: %v", constraint, err)
+	}
+}
+
+func (v *validator) pattern(path, pattern string) {
+	if pattern == "" {
+		v.add(path, "пустой шаблон")
+		return
+	}
+	if _, err := filepath.Match(strings.TrimPrefix(pattern, "!"), ""); err != nil {
+		v.add(path, "некорректный шаблон %q: %v", pattern, err)
+	}
+}
+
+// dest проверяет, что путь назначения относительный и не выходит за
+// пределы корня архива или установки.
+func (v *validator) dest(path, dest string) {
+	if dest == "" {
+		return
+	}
+	if !ValidDest(dest) {
+		v.add(path, "некорректный путь назначения %q: ожидается относительный путь без '..'", dest)
+	}
+}
+
+func (v *validator) result(file string) error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	// проблемы выводятся в порядке следования в файле
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		a, b := v.problems[i], v.problems[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return errors.NewConfigValidationError(file, v.problems)
+}
+
+func validatePacket(file string, doc *document, p *Packet) error {
+	v := &validator{doc: doc}
+	v.fields("", packetFields)
+
+	v.name("name", p.Name)
+	if p.Ver == "" {
+		v.add("ver", "версия пакета не задана")
+	} else if _, err := version.Parse(p.Ver); err != nil {
+		v.add("ver", "некорректная версия %q: ожидается semver, например 1.2.0", p.Ver)
+	}
+
+	if p.Format != "" && !contains(formats, p.Format) {
+		v.add("format", "неизвестный формат %q, допустимы: %s", p.Format, strings.Join(formats, ", "))
+	}
+
+	if len(p.Targets) == 0 {
+		v.add("targets", "не задано ни одной цели для упаковки")
+	}
+	for i, t := range p.Targets {
+		path := itemPath("targets", i)
+		v.fields(path, targetFields)
+		v.pattern(fieldPath(path, "path"), t.Path)
+		for j, e := range t.Exclude {
+			v.pattern(itemPath(fieldPath(path, "exclude"), j), e)
+		}
+		v.dest(fieldPath(path, "dest"), t.Dest)
+	}
+
+	for i, dep := range p.Packets {
+		path := itemPath("packets", i)
+		v.fields(path, dependencyFields)
+		v.name(fieldPath(path, "name"), dep.Name)
+		v.constraint(fieldPath(path, "ver"), dep.Ver)
+	}
+
+	return v.result(file)
+}
+
+func validatePackages(file string, doc *document, p *Packages) error {
+	v := &validator{doc: doc}
+	v.fields("", packagesFields)
+
+	if len(p.Packages) == 0 {
+		v.add("packages", "список пакетов пуст")
+	}
+
+	repos := make(map[string]bool)
+	for i, repo := range p.Repositories {
+		path := itemPath("repositories", i)
+		v.fields(path, repositoryFields)
+		switch {
+		case repo.Name == "":
+			v.add(fieldPath(path, "name"), "имя репозитория не задано")
+		case !repositoryNamePattern.MatchString(repo.Name):
+			v.add(fieldPath(path, "name"), "некорректное имя репозитория %q: допустимы буквы, цифры, '_' и '-'", repo.Name)
+		case repos[repo.Name]:
+			v.add(fieldPath(path, "name"), "репозиторий %q указан несколько раз", repo.Name)
+		}
+		repos[repo.Name] = true
+
+		if repo.URL != "" {
+			if _, err := url.Parse(repo.URL); err != nil {
+				v.add(fieldPath(path, "url"), "некорректный адрес репозитория %q", repo.URL)
+			}
+		}
+		if repo.Priority < 0 {
+			v.add(fieldPath(path, "priority"), "приоритет должен быть положительным")
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, pkg := range p.Packages {
+		path := itemPath("packages", i)
+		v.fields(path, packageFields)
+		v.name(fieldPath(path, "name"), pkg.Name)
+		v.constraint(fieldPath(path, "ver"), pkg.Ver)
+		if pkg.Repository != "" && !repositoryNamePattern.MatchString(pkg.Repository) {
+			v.add(fieldPath(path, "repository"), "некорректное имя репозитория %q", pkg.Repository)
+		}
+		v.dest(fieldPath(path, "dest"), pkg.Dest)
+
+		if pkg.Name != "" && seen[pkg.Name] {
+			v.add(fieldPath(path, "name"), "пакет %q указан несколько раз", pkg.Name)
+		}
+		seen[pkg.Name] = true
+	}
+
+	return v.result(file)
+}
+
+// decodeError превращает ошибку разбора файла в ошибку проверки с позицией,
+// если её удаётся определить.
+func decodeError(file string, data []byte, err error) error {
+	problem := errors.ConfigProblem{Message: err.Error()}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case stderrors.As(err, &syntaxErr):
+		pos := offsetPosition(data, int(syntaxErr.Offset))
+		problem = errors.ConfigProblem{Line: pos.line, Column: pos.column, Message: "синтаксическая ошибка: " + syntaxErr.Error()}
+	case stderrors.As(err, &typeErr):
+		pos := offsetPosition(data, int(typeErr.Offset))
+		problem = errors.ConfigProblem{
+			Line:    pos.line,
+			Column:  pos.column,
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("ожидается %s, получено %s", typeErr.Type, typeErr.Value),
+		}
+	}
+
+	return errors.NewConfigValidationError(file, []errors.ConfigProblem{problem})
+}
+
+// ValidDest сообщает, что dest — относительный путь, не выходящий за
+// пределы корня.
+func ValidDest(dest string) bool {
+	if strings.HasPrefix(dest, "/") || filepath.IsAbs(dest) || filepath.VolumeName(dest) != "" {
+		return false
+	}
+	clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(dest)))
+	return clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
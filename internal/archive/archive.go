@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -13,19 +14,64 @@ import (
 	"pm/internal/logger"
 )
 
+// maxLinkTarget ограничивает длину цели символической ссылки в zip,
+// где она хранится как обычное содержимое записи.
+const maxLinkTarget = 4096
+
+// CollectOptions задают общие для всех целей параметры сбора файлов.
+type CollectOptions struct {
+	// Root — корень архива; исключения сравниваются с путями относительно него.
+	Root string
+	// Ignore — исключения для всех целей, например из .pmignore.
+	Ignore []string
+	// IgnoreDir — директория, от которой отсчитываются шаблоны Ignore
+	// (для .pmignore — та, где он лежит); по умолчанию Root.
+	IgnoreDir string
+}
+
 func CollectFiles(log logger.LoggerInterface, targets []config.Target) ([]string, error) {
+	return CollectFilesWithOptions(log, targets, CollectOptions{Root: "."})
+}
+
+func CollectFilesWithOptions(log logger.LoggerInterface, targets []config.Target, opts CollectOptions) ([]string, error) {
+	files, _, err := CollectLayout(log, targets, opts)
+	return files, err
+}
+
+// CollectLayout собирает файлы так же, как CollectFilesWithOptions, и
+// вдобавок возвращает имена записей архива для файлов целей с dest.
+func CollectLayout(log logger.LoggerInterface, targets []config.Target, opts CollectOptions) ([]string, Layout, error) {
 	log.Debug("Начало сборки файлов", "колличество шаблонов", len(targets))
 
 	var files []string
+	layout := make(Layout)
 	var failedPatterns []string
+	seen := make(map[string]bool)
 
 	for i, target := range targets {
 		log.Debug("Обработка шаблона", "номер", i+1, "шаблон", target.Path)
 
-		matches, err := filepath.Glob(target.Path)
+		ignoreDir := opts.IgnoreDir
+		if ignoreDir == "" {
+			ignoreDir = opts.Root
+		}
+		scopes := []scopedMatcher{
+			{base: ignoreDir, anchored: opts.IgnoreDir != ""},
+			{base: opts.Root},
+		}
+		for i, patterns := range [][]string{opts.Ignore, target.Exclude} {
+			matcher, err := NewMatcher(patterns)
+			if err != nil {
+				log.Error("Ошибка обработки исключения", "шаблоны", patterns, "ошибка", err.Error())
+				return nil, nil, errors.NewArchiveCollectionError(target.Path, err)
+			}
+			scopes[i].matcher = matcher
+		}
+
+		matches, err := expandPattern(target.Path)
 		if err != nil {
 			log.Error("Ошибка обработки шаблона", "шаблон", target.Path, "ошибка", err.Error())
-			return nil, errors.NewArchiveCollectionError(target.Path, err)
+			return nil, nil, errors.NewArchiveCollectionError(target.Path, err)
 		}
 
 		log.Debug("Найдено совпадений", "шаблон", target.Path, "количество", len(matches))
@@ -36,32 +82,57 @@ func CollectFiles(log logger.LoggerInterface, targets []config.Target) ([]string
 			continue
 		}
 
+		var candidates []candidate
 		for _, match := range matches {
 			info, err := os.Stat(match)
 			if err != nil {
 				log.Warn("Не удалось получить информацию о файле", "файл", match, "ошибка", err.Error())
 				continue
 			}
-			if info.IsDir() {
-				log.Debug("Пропуск директории", "директория", match)
+			if !info.IsDir() {
+				candidates = append(candidates, candidate{path: match})
 				continue
 			}
 
-			if target.Exclude != "" {
-				baseName := filepath.Base(match)
-				matched, err := filepath.Match(target.Exclude, baseName)
+			switch {
+			case !hasMeta(target.Path):
+				// Директория указана явно — упаковывается целиком
+				log.Debug("Рекурсивный обход директории", "директория", match)
+				walked, err := walkDir(match, target.EmptyDirs)
 				if err != nil {
-					log.Error("Ошибка обработки исключения", "шаблон", target.Exclude, "файл", match, "ошибка", err.Error())
-					return nil, errors.NewArchiveCollectionError(target.Path, err)
+					log.Error("Ошибка обхода директории", "директория", match, "ошибка", err.Error())
+					return nil, nil, errors.NewArchiveCollectionError(target.Path, err)
 				}
-				if matched {
-					log.Debug("Файл исключен", "файл", match, "шаблон_исключения", target.Exclude)
-					continue
+				candidates = append(candidates, walked...)
+			case target.EmptyDirs && isEmptyDir(match):
+				candidates = append(candidates, candidate{path: match, dir: true})
+			default:
+				log.Debug("Пропуск директории", "директория", match)
+			}
+		}
+
+		for _, c := range candidates {
+			if excluded, rule := excludedBy(scopes, c.path, c.dir); excluded {
+				log.Debug("Файл исключен", "файл", c.path, "шаблон_исключения", rule)
+				continue
+			}
+
+			if seen[c.path] {
+				continue
+			}
+			seen[c.path] = true
+
+			if target.Dest != "" {
+				name, err := destName(target, c.path)
+				if err != nil {
+					log.Error("Ошибка определения пути назначения", "шаблон", target.Path, "файл", c.path, "ошибка", err.Error())
+					return nil, nil, errors.NewArchiveCollectionError(target.Path, err)
 				}
+				layout[c.path] = name
 			}
 
-			log.Debug("Файл добавлен в архив", "файл", match)
-			files = append(files, match)
+			log.Debug("Файл добавлен в архив", "файл", c.path)
+			files = append(files, c.path)
 		}
 	}
 
@@ -75,23 +146,50 @@ func CollectFiles(log logger.LoggerInterface, targets []config.Target) ([]string
 
 	if len(files) == 0 {
 		log.Error("Не найдено ни одного файла для архивации")
-		return nil, errors.NewArchiveCollectionError(
+		return nil, nil, errors.NewArchiveCollectionError(
 			"сбора файлов",
 			errors.ErrNoFilesFound,
 		)
 	}
 
+	if len(layout) > 0 {
+		if err := layout.checkDuplicates(opts.Root, files); err != nil {
+			log.Error("Конфликт путей назначения", "ошибка", err.Error())
+			return nil, nil, errors.NewArchiveCollectionError("сбора файлов", err)
+		}
+	}
+
 	log.Info("Сбор файлов завершен",
 		"найдено_файлов", len(files),
 		"количество_шаблонов", len(targets),
 	)
 
-	return files, nil
+	return files, layout, nil
 }
 
+// CreateZip упаковывает files, сохраняя их пути относительно директории,
+// в которой создаётся архив.
 func CreateZip(log logger.LoggerInterface, files []string, outputPath string) error {
+	return CreateZipWithRoot(log, files, outputPath, filepath.Dir(outputPath))
+}
+
+// CreateZipWithRoot упаковывает files, сохраняя их пути относительно root.
+func CreateZipWithRoot(log logger.LoggerInterface, files []string, outputPath, root string) error {
+	return CreateZipWithLayout(log, files, outputPath, root, nil)
+}
+
+// CreateZipWithLayout упаковывает files под именами из layout, а
+// остальные — относительно root.
+func CreateZipWithLayout(log logger.LoggerInterface, files []string, outputPath, root string, layout Layout) error {
+	return CreateZipWithManifest(log, files, outputPath, root, layout, nil)
+}
+
+// CreateZipWithManifest работает как CreateZipWithLayout и первой записью
+// добавляет описание пакета manifest, если оно задано.
+func CreateZipWithManifest(log logger.LoggerInterface, files []string, outputPath, root string, layout Layout, manifest []byte) error {
 	log.Info("Начало создания архива",
 		"выходной_файл", outputPath,
+		"корень", root,
 		"количество_файлов", len(files),
 	)
 
@@ -117,7 +215,12 @@ func CreateZip(log logger.LoggerInterface, files []string, outputPath string) er
 	zipWriter := zip.NewWriter(outFile)
 	defer zipWriter.Close()
 
-	root := filepath.Dir(outputPath)
+	if manifest != nil {
+		if err := writeZipManifest(zipWriter, manifest); err != nil {
+			log.Error("Ошибка добавления описания пакета в архив", "файл", ManifestFile, "ошибка", err.Error())
+			return errors.NewArchiveCreationError(outputPath, files, err)
+		}
+	}
 
 	for i, filePath := range files {
 		log.Debug("Добавление файла в архив",
@@ -155,7 +258,7 @@ func CreateZip(log logger.LoggerInterface, files []string, outputPath string) er
 			return errors.NewArchiveCreationError(outputPath, files, err)
 		}
 
-		rel, err := filepath.Rel(root, filePath)
+		name, err := layout.entryName(root, filePath)
 		if err != nil {
 			file.Close()
 			log.Error("Ошибка определения относительного пути",
@@ -164,9 +267,23 @@ func CreateZip(log logger.LoggerInterface, files []string, outputPath string) er
 			)
 			return errors.NewArchiveCreationError(outputPath, files, err)
 		}
-		header.Name = filepath.ToSlash(rel)
+		header.Name = name
 		header.Method = zip.Deflate
 
+		if info.IsDir() {
+			file.Close()
+			header.Name += "/"
+			header.Method = zip.Store
+			if _, err := zipWriter.CreateHeader(header); err != nil {
+				log.Error("Ошибка создания записи в архиве",
+					"файл", filePath,
+					"ошибка", err.Error(),
+				)
+				return errors.NewArchiveCreationError(outputPath, files, err)
+			}
+			continue
+		}
+
 		writer, err := zipWriter.CreateHeader(header)
 		if err != nil {
 			file.Close()
@@ -197,12 +314,34 @@ func CreateZip(log logger.LoggerInterface, files []string, outputPath string) er
 }
 
 func ExtractZip(log logger.LoggerInterface, zipPath, destDir string) error {
+	return ExtractZipWithLimits(log, zipPath, destDir, DefaultLimits)
+}
+
+func ExtractZipWithLimits(log logger.LoggerInterface, zipPath, destDir string, limits Limits) error {
 	log.Info("Начало распаковки архива",
 		"архив", zipPath,
 		"цель", destDir,
 	)
 
-	reader, err := zip.OpenReader(zipPath)
+	zipFile, err := os.Open(zipPath)
+	if err != nil {
+		log.Error("Ошибка открытия архива",
+			"архив", zipPath,
+			"ошибка", err.Error(),
+		)
+		return errors.NewArchiveExtractionError(zipPath, destDir, err)
+	}
+	defer zipFile.Close()
+
+	info, err := zipFile.Stat()
+	if err != nil {
+		return errors.NewArchiveExtractionError(zipPath, destDir, err)
+	}
+
+	// сжатые байты считаются по фактическому чтению из файла: размеру
+	// из заголовка записи доверять нельзя
+	compressed := &countingReaderAt{r: zipFile}
+	reader, err := zip.NewReader(compressed, info.Size())
 	if err != nil {
 		log.Error("Ошибка открытия архива",
 			"архив", zipPath,
@@ -210,18 +349,39 @@ func ExtractZip(log logger.LoggerInterface, zipPath, destDir string) error {
 		)
 		return errors.NewArchiveExtractionError(zipPath, destDir, err)
 	}
-	defer reader.Close()
 
 	log.Debug("Архив содержит файлов", "количество", len(reader.File))
 
+	b := newBudget(zipPath, limits, false)
 	for i, file := range reader.File {
-		filePath := filepath.Join(destDir, file.Name)
 		log.Debug("Обработка файла из архива",
 			"номер", i+1,
 			"всего", len(reader.File),
 			"файл", file.Name,
 		)
 
+		if isManifest(file.Name) {
+			continue
+		}
+
+		if err := b.addEntry(file.Name); err != nil {
+			log.Error("Превышен лимит распаковки", "архив", zipPath, "ошибка", err.Error())
+			return errors.NewArchiveExtractionError(zipPath, destDir, err)
+		}
+
+		filePath, err := entryPath(zipPath, destDir, file.Name)
+		if err == nil {
+			err = checkParents(zipPath, destDir, file.Name, filePath)
+		}
+		if err != nil {
+			log.Error("Небезопасная запись в архиве, распаковка прервана",
+				"архив", zipPath,
+				"запись", file.Name,
+				"ошибка", err.Error(),
+			)
+			return errors.NewArchiveExtractionError(zipPath, destDir, err)
+		}
+
 		if file.FileInfo().IsDir() {
 			log.Debug("Создание директории", "директория", filePath)
 			if err := os.MkdirAll(filePath, os.ModePerm); err != nil {
@@ -243,36 +403,14 @@ func ExtractZip(log logger.LoggerInterface, zipPath, destDir string) error {
 			return errors.NewArchiveExtractionError(zipPath, destDir, err)
 		}
 
-		log.Debug("Создание файла", "файл", filePath)
-		outFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
-		if err != nil {
-			log.Error("Ошибка создания файла",
-				"файл", filePath,
-				"ошибка", err.Error(),
-			)
-			return errors.NewArchiveExtractionError(zipPath, destDir, err)
-		}
-		defer outFile.Close()
-
-		log.Debug("Открытие файла из архива", "файл", file.Name)
-		rc, err := file.Open()
-		if err != nil {
-			log.Error("Ошибка открытия файла из архива",
-				"файл", file.Name,
-				"ошибка", err.Error(),
-			)
-			return errors.NewArchiveExtractionError(zipPath, destDir, err)
+		if file.Mode()&os.ModeSymlink != 0 {
+			if err := extractZipSymlink(log, zipPath, destDir, file, filePath); err != nil {
+				return errors.NewArchiveExtractionError(zipPath, destDir, err)
+			}
+			continue
 		}
-		defer rc.Close()
 
-		log.Debug("Копирование содержимого", "из", file.Name, "в", filePath)
-		_, err = io.Copy(outFile, rc)
-		if err != nil {
-			log.Error("Ошибка копирования содержимого",
-				"из", file.Name,
-				"в", filePath,
-				"ошибка", err.Error(),
-			)
+		if err := extractZipFile(log, b, compressed, file, filePath); err != nil {
 			return errors.NewArchiveExtractionError(zipPath, destDir, err)
 		}
 	}
@@ -286,9 +424,120 @@ func ExtractZip(log logger.LoggerInterface, zipPath, destDir string) error {
 	return nil
 }
 
+func extractZipFile(log logger.LoggerInterface, b *budget, compressed *countingReaderAt, file *zip.File, filePath string) error {
+	if err := removeSymlink(filePath); err != nil {
+		return err
+	}
+
+	log.Debug("Создание файла", "файл", filePath)
+	outFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
+	if err != nil {
+		log.Error("Ошибка создания файла",
+			"файл", filePath,
+			"ошибка", err.Error(),
+		)
+		return err
+	}
+	defer outFile.Close()
+
+	log.Debug("Открытие файла из архива", "файл", file.Name)
+	start := compressed.n
+	rc, err := file.Open()
+	if err != nil {
+		log.Error("Ошибка открытия файла из архива",
+			"файл", file.Name,
+			"ошибка", err.Error(),
+		)
+		return err
+	}
+	defer rc.Close()
+
+	log.Debug("Копирование содержимого", "из", file.Name, "в", filePath)
+	read := func() int64 { return compressed.n - start }
+	if err := b.copy(outFile, rc, file.Name, read); err != nil {
+		outFile.Close()
+		os.Remove(filePath)
+		log.Error("Ошибка копирования содержимого",
+			"из", file.Name,
+			"в", filePath,
+			"ошибка", err.Error(),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// extractZipSymlink создаёт символическую ссылку: в zip её цель хранится
+// как содержимое записи.
+func extractZipSymlink(log logger.LoggerInterface, zipPath, destDir string, file *zip.File, linkPath string) error {
+	rc, err := file.Open()
+	if err != nil {
+		log.Error("Ошибка открытия файла из архива",
+			"файл", file.Name,
+			"ошибка", err.Error(),
+		)
+		return err
+	}
+	target, err := io.ReadAll(io.LimitReader(rc, maxLinkTarget+1))
+	rc.Close()
+	if err != nil {
+		return err
+	}
+	if len(target) > maxLinkTarget {
+		return errors.NewUnsafePathError(zipPath, file.Name, "слишком длинная цель символической ссылки")
+	}
+
+	return createSymlink(log, zipPath, destDir, file.Name, linkPath, string(target))
+}
+
+func createSymlink(log logger.LoggerInterface, archivePath, destDir, name, linkPath, target string) error {
+	if err := checkLinkTarget(archivePath, destDir, name, linkPath, target); err != nil {
+		log.Error("Небезопасная символическая ссылка в архиве, распаковка прервана",
+			"архив", archivePath,
+			"запись", name,
+			"цель", target,
+		)
+		return err
+	}
+
+	log.Debug("Создание символической ссылки", "ссылка", linkPath, "цель", target)
+	if err := os.Remove(linkPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Symlink(filepath.FromSlash(target), linkPath); err != nil {
+		log.Error("Ошибка создания символической ссылки",
+			"ссылка", linkPath,
+			"ошибка", err.Error(),
+		)
+		return err
+	}
+	return nil
+}
+
+// CreateTarGz упаковывает files, сохраняя их пути относительно директории,
+// в которой создаётся архив, так же как CreateZip.
 func CreateTarGz(log logger.LoggerInterface, files []string, outputPath string) error {
+	return CreateTarGzWithRoot(log, files, outputPath, filepath.Dir(outputPath))
+}
+
+// CreateTarGzWithRoot упаковывает files, сохраняя их пути относительно root.
+func CreateTarGzWithRoot(log logger.LoggerInterface, files []string, outputPath, root string) error {
+	return CreateTarGzWithLayout(log, files, outputPath, root, nil)
+}
+
+// CreateTarGzWithLayout упаковывает files под именами из layout, а
+// остальные — относительно root.
+func CreateTarGzWithLayout(log logger.LoggerInterface, files []string, outputPath, root string, layout Layout) error {
+	return CreateTarGzWithManifest(log, files, outputPath, root, layout, nil)
+}
+
+// CreateTarGzWithManifest работает как CreateTarGzWithLayout и первой
+// записью добавляет описание пакета manifest, если оно задано.
+func CreateTarGzWithManifest(log logger.LoggerInterface, files []string, outputPath, root string, layout Layout, manifest []byte) error {
 	log.Info("Начало создания tar.gz архива",
 		"выходной_файл", outputPath,
+		"корень", root,
 		"количество_файлов", len(files),
 	)
 
@@ -317,8 +566,15 @@ func CreateTarGz(log logger.LoggerInterface, files []string, outputPath string)
 	tw := tar.NewWriter(gw)
 	defer tw.Close()
 
+	if manifest != nil {
+		if err := writeTarManifest(tw, manifest); err != nil {
+			log.Error("Ошибка добавления описания пакета в архив", "файл", ManifestFile, "ошибка", err.Error())
+			return errors.NewArchiveCreationError(outputPath, files, err)
+		}
+	}
+
 	for _, filePath := range files {
-		err := addToTar(tw, filePath)
+		err := addToTar(tw, root, layout, filePath)
 		if err != nil {
 			log.Error("Ошибка добавления файла в tar",
 				"файл", filePath,
@@ -337,6 +593,10 @@ func CreateTarGz(log logger.LoggerInterface, files []string, outputPath string)
 }
 
 func ExtractTarGz(log logger.LoggerInterface, tarGzPath, destDir string) error {
+	return ExtractTarGzWithLimits(log, tarGzPath, destDir, DefaultLimits)
+}
+
+func ExtractTarGzWithLimits(log logger.LoggerInterface, tarGzPath, destDir string, limits Limits) error {
 	log.Info("Начало распаковки tar.gz архива",
 		"архив", tarGzPath,
 		"цель", destDir,
@@ -352,7 +612,8 @@ func ExtractTarGz(log logger.LoggerInterface, tarGzPath, destDir string) error {
 	}
 	defer file.Close()
 
-	gzReader, err := gzip.NewReader(file)
+	compressed := &countingReader{r: file}
+	gzReader, err := gzip.NewReader(compressed)
 	if err != nil {
 		log.Error("Ошибка создания gzip ридера",
 			"архив", tarGzPath,
@@ -364,6 +625,7 @@ func ExtractTarGz(log logger.LoggerInterface, tarGzPath, destDir string) error {
 
 	tarReader := tar.NewReader(gzReader)
 	fileCount := 0
+	b := newBudget(tarGzPath, limits, true)
 
 	for {
 		header, err := tarReader.Next()
@@ -378,13 +640,34 @@ func ExtractTarGz(log logger.LoggerInterface, tarGzPath, destDir string) error {
 			return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
 		}
 
+		if isManifest(header.Name) {
+			continue
+		}
+
 		fileCount++
-		target := filepath.Join(destDir, header.Name)
 		log.Debug("Обработка файла из архива",
 			"файл", header.Name,
 			"тип", header.Typeflag,
 		)
 
+		if err := b.addEntry(header.Name); err != nil {
+			log.Error("Превышен лимит распаковки", "архив", tarGzPath, "ошибка", err.Error())
+			return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
+		}
+
+		target, err := entryPath(tarGzPath, destDir, header.Name)
+		if err == nil {
+			err = checkParents(tarGzPath, destDir, header.Name, target)
+		}
+		if err != nil {
+			log.Error("Небезопасная запись в архиве, распаковка прервана",
+				"архив", tarGzPath,
+				"запись", header.Name,
+				"ошибка", err.Error(),
+			)
+			return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
+		}
+
 		switch header.Typeflag {
 		case tar.TypeDir:
 			if _, err := os.Stat(target); err != nil {
@@ -406,6 +689,10 @@ func ExtractTarGz(log logger.LoggerInterface, tarGzPath, destDir string) error {
 				return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
 			}
 
+			if err := removeSymlink(target); err != nil {
+				return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
+			}
+
 			outFile, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(header.Mode))
 			if err != nil {
 				log.Error("Ошибка создания файла",
@@ -415,8 +702,9 @@ func ExtractTarGz(log logger.LoggerInterface, tarGzPath, destDir string) error {
 				return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
 			}
 
-			if _, err := io.Copy(outFile, tarReader); err != nil {
+			if err := b.copy(outFile, tarReader, header.Name, func() int64 { return compressed.n }); err != nil {
 				outFile.Close()
+				os.Remove(target)
 				log.Error("Ошибка копирования содержимого",
 					"из", header.Name,
 					"в", target,
@@ -425,6 +713,48 @@ func ExtractTarGz(log logger.LoggerInterface, tarGzPath, destDir string) error {
 				return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
 			}
 			outFile.Close()
+
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+				return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
+			}
+			if err := createSymlink(log, tarGzPath, destDir, header.Name, target, header.Linkname); err != nil {
+				return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
+			}
+
+		case tar.TypeLink:
+			// Цель жёсткой ссылки задаётся относительно корня архива
+			source, err := entryPath(tarGzPath, destDir, header.Linkname)
+			if err == nil {
+				err = checkParents(tarGzPath, destDir, header.Linkname, source)
+			}
+			if err != nil {
+				log.Error("Небезопасная жёсткая ссылка в архиве, распаковка прервана",
+					"архив", tarGzPath,
+					"запись", header.Name,
+					"цель", header.Linkname,
+				)
+				return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+				return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
+			}
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
+			}
+			if err := os.Link(source, target); err != nil {
+				log.Error("Ошибка создания жёсткой ссылки",
+					"ссылка", target,
+					"ошибка", err.Error(),
+				)
+				return errors.NewArchiveExtractionError(tarGzPath, destDir, err)
+			}
+
+		default:
+			log.Warn("Пропуск записи неподдерживаемого типа",
+				"файл", header.Name,
+				"тип", header.Typeflag,
+			)
 		}
 	}
 
@@ -445,7 +775,7 @@ func ExtractTgz(log logger.LoggerInterface, tgzPath, destDir string) error {
 	return ExtractTarGz(log, tgzPath, destDir)
 }
 
-func addToTar(tw *tar.Writer, filePath string) error {
+func addToTar(tw *tar.Writer, root string, layout Layout, filePath string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return err
@@ -462,11 +792,14 @@ func addToTar(tw *tar.Writer, filePath string) error {
 		return err
 	}
 
-	relPath, err := filepath.Rel(filepath.Dir(filePath), filePath)
+	name, err := layout.entryName(root, filePath)
 	if err != nil {
 		return err
 	}
-	header.Name = relPath
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
 
 	if err := tw.WriteHeader(header); err != nil {
 		return err
@@ -479,3 +812,26 @@ func addToTar(tw *tar.Writer, filePath string) error {
 	_, err = io.Copy(tw, file)
 	return err
 }
+
+// entryName возвращает имя записи для filePath относительно корня архива.
+// Файлы вне корня не упаковываются: при распаковке такая запись вышла бы
+// за пределы директории назначения.
+func entryName(root, filePath string) (string, error) {
+	rel, err := filepath.Rel(root, filePath)
+	if err != nil {
+		return "", err
+	}
+	if escapes(rel) {
+		return "", fmt.Errorf("файл %s находится вне корня архива %s", filePath, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// relativeTo возвращает путь path относительно root, а если это
+// невозможно — сам путь.
+func relativeTo(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil && !escapes(rel) {
+		return rel
+	}
+	return path
+}
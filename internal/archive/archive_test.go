@@ -1,11 +1,18 @@
 package archive
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	stderrors "errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"pm/config"
+	"pm/internal/errors"
 	"runtime"
 	"strings"
 	"testing"
@@ -116,6 +123,10 @@ func TestCollectFiles(t *testing.T) {
 	createFile(t, tempDir, "file2.go", "test")
 	createFile(t, tempDir, "subdir/file3.txt", "test")
 	createFile(t, tempDir, "exclude_me.tmp", "test")
+	createFile(t, tempDir, "subdir/deep/file4.go", "test")
+	if err := os.MkdirAll(filepath.Join(tempDir, "subdir", "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
 
 	tests := []struct {
 		name        string
@@ -163,7 +174,7 @@ func TestCollectFiles(t *testing.T) {
 			targets: []config.Target{
 				{
 					Path:    filepath.Join(tempDir, "*.*"),
-					Exclude: "*.tmp",
+					Exclude: config.Patterns{"*.tmp"},
 				},
 			},
 			wantFiles: []string{
@@ -178,7 +189,7 @@ func TestCollectFiles(t *testing.T) {
 			targets: []config.Target{
 				{
 					Path:    filepath.Join(tempDir, "*.txt"),
-					Exclude: "foo[bar",
+					Exclude: config.Patterns{"foo[bar"},
 				},
 			},
 			wantFiles:   nil,
@@ -197,6 +208,62 @@ func TestCollectFiles(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "шаблон ** на любой глубине",
+			targets: []config.Target{
+				{Path: filepath.Join(tempDir, "**", "*.go")},
+			},
+			wantFiles: []string{
+				filepath.Join(tempDir, "file2.go"),
+				filepath.Join(tempDir, "subdir", "deep", "file4.go"),
+			},
+			expectError: false,
+		},
+		{
+			name: "шаблон ** в конце пути",
+			targets: []config.Target{
+				{Path: filepath.Join(tempDir, "subdir", "**")},
+			},
+			wantFiles: []string{
+				filepath.Join(tempDir, "subdir", "file3.txt"),
+				filepath.Join(tempDir, "subdir", "deep", "file4.go"),
+			},
+			expectError: false,
+		},
+		{
+			name: "директория обходится рекурсивно",
+			targets: []config.Target{
+				{Path: filepath.Join(tempDir, "subdir"), Exclude: config.Patterns{"*.txt"}},
+			},
+			wantFiles: []string{
+				filepath.Join(tempDir, "subdir", "deep", "file4.go"),
+			},
+			expectError: false,
+			logContains: []string{"Рекурсивный обход директории"},
+		},
+		{
+			name: "пустые директории",
+			targets: []config.Target{
+				{Path: filepath.Join(tempDir, "subdir"), EmptyDirs: true},
+			},
+			wantFiles: []string{
+				filepath.Join(tempDir, "subdir", "file3.txt"),
+				filepath.Join(tempDir, "subdir", "deep", "file4.go"),
+				filepath.Join(tempDir, "subdir", "empty"),
+			},
+			expectError: false,
+		},
+		{
+			name: "пересекающиеся шаблоны без дублей",
+			targets: []config.Target{
+				{Path: filepath.Join(tempDir, "*.txt")},
+				{Path: filepath.Join(tempDir, "file1.*")},
+			},
+			wantFiles: []string{
+				filepath.Join(tempDir, "file1.txt"),
+			},
+			expectError: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -405,3 +472,758 @@ func TestExtractZip(t *testing.T) {
 		})
 	}
 }
+
+type testEntry struct {
+	name    string
+	content string
+	link    string
+}
+
+func createZipEntries(t *testing.T, path string, entries []testEntry) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	zipWriter := zip.NewWriter(out)
+	defer zipWriter.Close()
+
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		content := e.content
+		if e.link != "" {
+			header.SetMode(os.ModeSymlink | 0777)
+			content = e.link
+		} else if strings.HasSuffix(e.name, "/") {
+			header.SetMode(os.ModeDir | 0755)
+		} else {
+			header.SetMode(0644)
+		}
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = writer.Write([]byte(content))
+	}
+}
+
+func createTarGzEntries(t *testing.T, path string, entries []testEntry) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	defer gw.Close()
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.content))}
+		if e.link != "" {
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = e.link
+			header.Size = 0
+		} else if strings.HasSuffix(e.name, "/") {
+			header.Typeflag = tar.TypeDir
+			header.Mode = 0755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			_, _ = tw.Write([]byte(e.content))
+		}
+	}
+}
+
+func TestExtractUnsafeEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []testEntry
+		unsafe  bool
+	}{
+		{
+			name:    "выход через ..",
+			entries: []testEntry{{name: "../../evil.txt", content: "x"}},
+			unsafe:  true,
+		},
+		{
+			name:    "абсолютный путь",
+			entries: []testEntry{{name: "/tmp/evil.txt", content: "x"}},
+			unsafe:  true,
+		},
+		{
+			name:    "ссылка за пределы директории",
+			entries: []testEntry{{name: "link", link: "../outside"}},
+			unsafe:  true,
+		},
+		{
+			name:    "ссылка на абсолютный путь",
+			entries: []testEntry{{name: "link", link: "/etc"}},
+			unsafe:  true,
+		},
+		{
+			name: "запись через созданную ссылку",
+			entries: []testEntry{
+				{name: "dir/link", link: "../.."},
+				{name: "dir/link/evil.txt", content: "x"},
+			},
+			unsafe: true,
+		},
+		{
+			name: "выход в два шага через ссылку на себя",
+			entries: []testEntry{
+				{name: "d", link: "."},
+				{name: "d/l", link: ".."},
+			},
+			unsafe: true,
+		},
+		{
+			name: "выход через ссылку в цели",
+			entries: []testEntry{
+				{name: "sub/up", link: ".."},
+				{name: "l", link: "sub/up/.."},
+			},
+			unsafe: true,
+		},
+		{
+			name:    "служебная директория pm",
+			entries: []testEntry{{name: ".pm/installed.json", content: "{}"}},
+			unsafe:  true,
+		},
+		{
+			name:    "служебная директория pm через ..",
+			entries: []testEntry{{name: "dir/../.pm/tmp/x", content: "x"}},
+			unsafe:  true,
+		},
+		{
+			name: "безопасные пути и ссылки",
+			entries: []testEntry{
+				{name: "a/../b.txt", content: "b"},
+				{name: "dir/c.txt", content: "c"},
+				{name: "dir/link", link: "../b.txt"},
+			},
+		},
+	}
+
+	extractors := []struct {
+		name    string
+		ext     string
+		create  func(*testing.T, string, []testEntry)
+		extract func(log *mockLogger, archive, dest string) error
+	}{
+		{"zip", ".zip", createZipEntries, func(log *mockLogger, archive, dest string) error { return ExtractZip(log, archive, dest) }},
+		{"tar.gz", ".tar.gz", createTarGzEntries, func(log *mockLogger, archive, dest string) error { return ExtractTarGz(log, archive, dest) }},
+	}
+
+	for _, ex := range extractors {
+		for _, tt := range tests {
+			t.Run(ex.name+"/"+tt.name, func(t *testing.T) {
+				if runtime.GOOS == "windows" {
+					t.Skip("символические ссылки требуют прав администратора")
+				}
+
+				tempDir := t.TempDir()
+				archivePath := filepath.Join(tempDir, "test"+ex.ext)
+				destDir := filepath.Join(tempDir, "root", "dest")
+				ex.create(t, archivePath, tt.entries)
+
+				mockLog := &mockLogger{}
+				err := ex.extract(mockLog, archivePath, destDir)
+
+				if !tt.unsafe {
+					if err != nil {
+						t.Fatalf("Не ожидалась ошибка: %v", err)
+					}
+					data, err := os.ReadFile(filepath.Join(destDir, "dir", "link"))
+					if err != nil || string(data) != "b" {
+						t.Errorf("Ссылка dir/link не указывает на b.txt: %q, %v", data, err)
+					}
+					return
+				}
+
+				var unsafeErr *errors.UnsafePathError
+				if !stderrors.As(err, &unsafeErr) {
+					t.Fatalf("Ожидалась ошибка UnsafePathError, получено: %v", err)
+				}
+				for _, p := range []string{"evil.txt", "root/evil.txt", "root/outside"} {
+					if _, err := os.Lstat(filepath.Join(tempDir, p)); err == nil {
+						t.Errorf("Создан файл за пределами директории назначения: %s", p)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	zeros := strings.Repeat("\x00", 2<<20)
+	small := []testEntry{{name: "a.txt", content: "a"}, {name: "b.txt", content: "b"}, {name: "c.txt", content: "c"}}
+
+	tests := []struct {
+		name      string
+		entries   []testEntry
+		limits    Limits
+		wantLimit string
+	}{
+		{
+			name:    "в пределах лимитов",
+			entries: small,
+			limits:  DefaultLimits,
+		},
+		{
+			name:      "слишком много записей",
+			entries:   small,
+			limits:    Limits{MaxEntries: 2},
+			wantLimit: "количество записей",
+		},
+		{
+			name:      "слишком большой файл",
+			entries:   []testEntry{{name: "big.bin", content: zeros}},
+			limits:    Limits{MaxFileSize: 1 << 20},
+			wantLimit: "размер файла",
+		},
+		{
+			name:      "превышен суммарный размер",
+			entries:   []testEntry{{name: "1.bin", content: zeros}, {name: "2.bin", content: zeros}},
+			limits:    Limits{MaxTotalSize: 3 << 20},
+			wantLimit: "суммарный размер",
+		},
+		{
+			name:      "слишком высокая степень сжатия",
+			entries:   []testEntry{{name: "bomb.bin", content: zeros}},
+			limits:    Limits{MaxRatio: 10},
+			wantLimit: "степень сжатия",
+		},
+		{
+			name:    "нулевые лимиты не ограничивают",
+			entries: []testEntry{{name: "bomb.bin", content: zeros}},
+			limits:  Limits{},
+		},
+	}
+
+	extractors := []struct {
+		name    string
+		ext     string
+		create  func(*testing.T, string, []testEntry)
+		extract func(log *mockLogger, archive, dest string, limits Limits) error
+	}{
+		{"zip", ".zip", createZipEntries, func(log *mockLogger, archive, dest string, limits Limits) error {
+			return ExtractZipWithLimits(log, archive, dest, limits)
+		}},
+		{"tar.gz", ".tar.gz", createTarGzEntries, func(log *mockLogger, archive, dest string, limits Limits) error {
+			return ExtractTarGzWithLimits(log, archive, dest, limits)
+		}},
+	}
+
+	for _, ex := range extractors {
+		for _, tt := range tests {
+			t.Run(ex.name+"/"+tt.name, func(t *testing.T) {
+				tempDir := t.TempDir()
+				archivePath := filepath.Join(tempDir, "test"+ex.ext)
+				ex.create(t, archivePath, tt.entries)
+
+				err := ex.extract(&mockLogger{}, archivePath, filepath.Join(tempDir, "dest"), tt.limits)
+
+				if tt.wantLimit == "" {
+					if err != nil {
+						t.Fatalf("Не ожидалась ошибка: %v", err)
+					}
+					return
+				}
+
+				var limitErr *errors.ArchiveLimitError
+				if !stderrors.As(err, &limitErr) {
+					t.Fatalf("Ожидалась ошибка ArchiveLimitError, получено: %v", err)
+				}
+				if limitErr.Limit != tt.wantLimit {
+					t.Errorf("Превышен лимит %q, ожидался %q", limitErr.Limit, tt.wantLimit)
+				}
+			})
+		}
+	}
+}
+
+func tarGzNames(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gr.Close()
+
+	var names []string
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+}
+
+func zipNames(t *testing.T, path string) []string {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestCreateArchiveRoot(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	files := []string{
+		createFile(t, srcDir, "a/config.yml", "a"),
+		createFile(t, srcDir, "b/config.yml", "b"),
+	}
+
+	tests := []struct {
+		name        string
+		root        string
+		want        []string
+		expectError bool
+	}{
+		{
+			name: "корень — директория с исходниками",
+			root: srcDir,
+			want: []string{"a/config.yml", "b/config.yml"},
+		},
+		{
+			name: "корень выше по дереву",
+			root: tempDir,
+			want: []string{"src/a/config.yml", "src/b/config.yml"},
+		},
+		{
+			name:        "файл вне корня",
+			root:        filepath.Join(srcDir, "a"),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zipPath := filepath.Join(t.TempDir(), "test.zip")
+			tarPath := filepath.Join(t.TempDir(), "test.tar.gz")
+
+			zipErr := CreateZipWithRoot(&mockLogger{}, files, zipPath, tt.root)
+			tarErr := CreateTarGzWithRoot(&mockLogger{}, files, tarPath, tt.root)
+
+			if tt.expectError {
+				if zipErr == nil || tarErr == nil {
+					t.Fatalf("Ожидалась ошибка: zip=%v, tar.gz=%v", zipErr, tarErr)
+				}
+				return
+			}
+			if zipErr != nil || tarErr != nil {
+				t.Fatalf("Не ожидалась ошибка: zip=%v, tar.gz=%v", zipErr, tarErr)
+			}
+
+			if got := zipNames(t, zipPath); !equalStringSlices(got, tt.want) {
+				t.Errorf("Файлы в zip не совпадают.\nОжидалось: %v\nПолучено: %v", tt.want, got)
+			}
+			if got := tarGzNames(t, tarPath); !equalStringSlices(got, tt.want) {
+				t.Errorf("Файлы в tar.gz не совпадают.\nОжидалось: %v\nПолучено: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCollectFilesExcludes(t *testing.T) {
+	tempDir := t.TempDir()
+
+	createFile(t, tempDir, "main.go", "package main")
+	createFile(t, tempDir, "notes.tmp", "x")
+	createFile(t, tempDir, "keep.tmp", "x")
+	createFile(t, tempDir, "build/app", "x")
+	createFile(t, tempDir, "build/keep.txt", "x")
+	createFile(t, tempDir, "src/build/gen.go", "package gen")
+	createFile(t, tempDir, "src/main.go~", "x")
+
+	tests := []struct {
+		name    string
+		exclude config.Patterns
+		ignore  []string
+		// ignoreDir — директория .pmignore относительно tempDir
+		ignoreDir string
+		wantFiles []string
+	}{
+		{
+			name:    "несколько шаблонов по имени",
+			exclude: config.Patterns{"*.tmp", "*~"},
+			wantFiles: []string{
+				"main.go", "build/app", "build/keep.txt", "src/build/gen.go",
+			},
+		},
+		{
+			name:    "полный путь от корня",
+			exclude: config.Patterns{"build/**"},
+			wantFiles: []string{
+				"main.go", "notes.tmp", "keep.tmp", "src/build/gen.go", "src/main.go~",
+			},
+		},
+		{
+			name:    "директория на любой глубине",
+			exclude: config.Patterns{"build/"},
+			wantFiles: []string{
+				"main.go", "notes.tmp", "keep.tmp", "src/main.go~",
+			},
+		},
+		{
+			name:    "отрицание",
+			exclude: config.Patterns{"*.tmp", "!keep.tmp", "build/**", "!build/keep.txt"},
+			wantFiles: []string{
+				"main.go", "keep.tmp", "build/keep.txt", "src/build/gen.go", "src/main.go~",
+			},
+		},
+		{
+			name:    "исключения цели применяются после .pmignore",
+			ignore:  []string{"*.tmp", "src/"},
+			exclude: config.Patterns{"!keep.tmp"},
+			wantFiles: []string{
+				"main.go", "keep.tmp", "build/app", "build/keep.txt",
+			},
+		},
+		{
+			name:      ".pmignore во вложенной директории действует только в ней",
+			ignore:    []string{"build/", "*~", "/main.go"},
+			ignoreDir: "src",
+			wantFiles: []string{
+				"main.go", "notes.tmp", "keep.tmp", "build/app", "build/keep.txt",
+			},
+		},
+		{
+			name:      "исключения цели отсчитываются от корня, а не от .pmignore",
+			ignore:    []string{"gen.go"},
+			ignoreDir: "src",
+			exclude:   config.Patterns{"build/**", "!src/build/gen.go"},
+			wantFiles: []string{
+				"main.go", "notes.tmp", "keep.tmp", "src/build/gen.go", "src/main.go~",
+			},
+		},
+		{
+			name:      "директория, исключённая .pmignore, не возвращается исключением цели",
+			ignore:    []string{"build/"},
+			ignoreDir: "src",
+			exclude:   config.Patterns{"!src/build/gen.go"},
+			wantFiles: []string{
+				"main.go", "notes.tmp", "keep.tmp", "build/app", "build/keep.txt", "src/main.go~",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := []config.Target{{Path: filepath.Join(tempDir, "**"), Exclude: tt.exclude}}
+			opts := CollectOptions{Root: tempDir, Ignore: tt.ignore}
+			if tt.ignoreDir != "" {
+				opts.IgnoreDir = filepath.Join(tempDir, tt.ignoreDir)
+			}
+			got, err := CollectFilesWithOptions(&mockLogger{}, targets, opts)
+			if err != nil {
+				t.Fatalf("Не ожидалась ошибка: %v", err)
+			}
+
+			var want []string
+			for _, f := range tt.wantFiles {
+				want = append(want, filepath.Join(tempDir, filepath.FromSlash(f)))
+			}
+			if !equalStringSlices(got, want) {
+				t.Errorf("Файлы не совпадают.\nОжидалось: %v\nПолучено: %v", want, got)
+			}
+		})
+	}
+}
+
+func TestCollectLayout(t *testing.T) {
+	tempDir := t.TempDir()
+	createFile(t, tempDir, "build/app", "x")
+	createFile(t, tempDir, "build/tool", "x")
+	createFile(t, tempDir, "configs/app.conf", "x")
+	createFile(t, tempDir, "configs/conf.d/log.conf", "x")
+	createFile(t, tempDir, "README.md", "x")
+	createFile(t, tempDir, "other/app", "x")
+
+	tests := []struct {
+		name        string
+		targets     []config.Target
+		want        []string
+		expectError bool
+	}{
+		{
+			name: "шаблон, директория и файл",
+			targets: []config.Target{
+				{Path: filepath.Join(tempDir, "build", "*"), Dest: "bin/"},
+				{Path: filepath.Join(tempDir, "configs"), Dest: "etc/app"},
+				{Path: filepath.Join(tempDir, "README.md"), Dest: "share/doc/app"},
+			},
+			want: []string{"bin/app", "bin/tool", "etc/app/app.conf", "etc/app/conf.d/log.conf", "share/doc/app/README.md"},
+		},
+		{
+			name: "цели без dest упаковываются относительно корня",
+			targets: []config.Target{
+				{Path: filepath.Join(tempDir, "build", "app"), Dest: "bin"},
+				{Path: filepath.Join(tempDir, "README.md")},
+			},
+			want: []string{"bin/app", "README.md"},
+		},
+		{
+			name: "два файла под одним именем",
+			targets: []config.Target{
+				{Path: filepath.Join(tempDir, "build", "app"), Dest: "bin"},
+				{Path: filepath.Join(tempDir, "other", "app"), Dest: "bin"},
+			},
+			expectError: true,
+		},
+		{
+			name: "файл на месте директории",
+			targets: []config.Target{
+				{Path: filepath.Join(tempDir, "build", "app"), Dest: "bin"},
+				{Path: filepath.Join(tempDir, "configs", "app.conf"), Dest: "bin/app"},
+			},
+			expectError: true,
+		},
+		{
+			name:        "dest за пределами корня",
+			targets:     []config.Target{{Path: filepath.Join(tempDir, "README.md"), Dest: "../doc"}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, layout, err := CollectLayout(&mockLogger{}, tt.targets, CollectOptions{Root: tempDir})
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Ожидалась ошибка, но её не было")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Не ожидалась ошибка: %v", err)
+			}
+
+			zipPath := filepath.Join(t.TempDir(), "test.zip")
+			tarPath := filepath.Join(t.TempDir(), "test.tar.gz")
+			if err := CreateZipWithLayout(&mockLogger{}, files, zipPath, tempDir, layout); err != nil {
+				t.Fatal(err)
+			}
+			if err := CreateTarGzWithLayout(&mockLogger{}, files, tarPath, tempDir, layout); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := zipNames(t, zipPath); !equalStringSlices(got, tt.want) {
+				t.Errorf("Файлы в zip не совпадают.\nОжидалось: %v\nПолучено: %v", tt.want, got)
+			}
+			if got := tarGzNames(t, tarPath); !equalStringSlices(got, tt.want) {
+				t.Errorf("Файлы в tar.gz не совпадают.\nОжидалось: %v\nПолучено: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestArchiveManifest(t *testing.T) {
+	srcDir := t.TempDir()
+	files := []string{createFile(t, srcDir, "bin/app", "app")}
+	manifest := []byte(`{"name": "app", "ver": "1.0"}`)
+
+	tests := []struct {
+		name    string
+		format  string
+		create  func(outputPath string, manifest []byte) error
+		extract func(archivePath, destDir string) error
+	}{
+		{
+			name:   "zip",
+			format: "zip",
+			create: func(outputPath string, manifest []byte) error {
+				return CreateZipWithManifest(&mockLogger{}, files, outputPath, srcDir, nil, manifest)
+			},
+			extract: func(archivePath, destDir string) error {
+				return ExtractZip(&mockLogger{}, archivePath, destDir)
+			},
+		},
+		{
+			name:   "tar.gz",
+			format: "tar.gz",
+			create: func(outputPath string, manifest []byte) error {
+				return CreateTarGzWithManifest(&mockLogger{}, files, outputPath, srcDir, nil, manifest)
+			},
+			extract: func(archivePath, destDir string) error {
+				return ExtractTarGz(&mockLogger{}, archivePath, destDir)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), "app-1.0."+tt.format)
+			if err := tt.create(archivePath, manifest); err != nil {
+				t.Fatalf("Ошибка создания архива: %v", err)
+			}
+
+			got, err := ReadManifest(archivePath, tt.format)
+			if err != nil {
+				t.Fatalf("Ошибка ReadManifest: %v", err)
+			}
+			if string(got) != string(manifest) {
+				t.Errorf("Описание пакета = %q, ожидалось %q", got, manifest)
+			}
+
+			listed, err := Files(archivePath, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalStringSlices(listed, []string{"bin/app"}) {
+				t.Errorf("Files() = %v, описание пакета не должно попадать в список", listed)
+			}
+
+			destDir := t.TempDir()
+			if err := tt.extract(archivePath, destDir); err != nil {
+				t.Fatalf("Ошибка распаковки: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(destDir, filepath.FromSlash(ManifestFile))); !os.IsNotExist(err) {
+				t.Errorf("Описание пакета распаковано в директорию назначения: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(destDir, "bin", "app")); err != nil {
+				t.Errorf("Файл пакета не распакован: %v", err)
+			}
+
+			plain := filepath.Join(t.TempDir(), "plain."+tt.format)
+			if err := tt.create(plain, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := ReadManifest(plain, tt.format); err != nil || got != nil {
+				t.Errorf("Архив без описания: ReadManifest() = %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestContents(t *testing.T) {
+	entries := []testEntry{
+		{name: "./bin/"},
+		{name: "bin/app", content: "app"},
+		{name: "var/"},
+		{name: "var/log/"},
+		{name: "var/cache/tmp/"},
+		{name: "data/"},
+		{name: ManifestFile, content: "{}"},
+	}
+
+	tests := []struct {
+		format string
+		create func(t *testing.T, path string, entries []testEntry)
+	}{
+		{format: "zip", create: createZipEntries},
+		{format: "tar.gz", create: createTarGzEntries},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), "app."+tt.format)
+			tt.create(t, archivePath, entries)
+
+			files, dirs, err := Contents(archivePath, tt.format)
+			if err != nil {
+				t.Fatalf("Ошибка Contents: %v", err)
+			}
+			if !equalStringSlices(files, []string{"bin/app"}) {
+				t.Errorf("Файлы = %v, ожидался только bin/app", files)
+			}
+			// bin и var не пусты, в списке только листовые директории
+			if want := []string{"data", "var/cache/tmp", "var/log"}; !equalStringSlices(dirs, want) {
+				t.Errorf("Пустые директории = %v, ожидалось %v", dirs, want)
+			}
+		})
+	}
+}
+
+func TestCreateReservedDir(t *testing.T) {
+	srcDir := t.TempDir()
+	files := []string{
+		createFile(t, srcDir, "bin/app", "app"),
+		createFile(t, srcDir, filepath.Join(".pm", "packet.json"), "{}"),
+	}
+
+	tests := []struct {
+		name   string
+		create func(outputPath string, layout Layout) error
+	}{
+		{"zip", func(outputPath string, layout Layout) error {
+			return CreateZipWithLayout(&mockLogger{}, files[:1], outputPath, srcDir, layout)
+		}},
+		{"tar.gz", func(outputPath string, layout Layout) error {
+			return CreateTarGzWithLayout(&mockLogger{}, files[:1], outputPath, srcDir, layout)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "app."+tt.name)
+			if err := tt.create(out, nil); err != nil {
+				t.Fatalf("Ошибка создания архива: %v", err)
+			}
+			// файл пакета не должен пропасть молча, подменив описание пакета
+			if err := tt.create(out, Layout{files[0]: ".pm/installed.json"}); err == nil {
+				t.Error("Ожидалась ошибка для файла в служебной директории")
+			}
+		})
+	}
+
+	if err := CreateZipWithRoot(&mockLogger{}, files, filepath.Join(t.TempDir(), "app.zip"), srcDir); err == nil {
+		t.Error("Ожидалась ошибка для файла .pm/packet.json")
+	}
+}
+
+// TestExtractZipForgedCompressedSize проверяет, что степень сжатия zip
+// считается по прочитанным байтам, а не по размеру из заголовка записи.
+func TestExtractZipForgedCompressedSize(t *testing.T) {
+	tempDir := t.TempDir()
+	archivePath := filepath.Join(tempDir, "bomb.zip")
+	content := strings.Repeat("\x00", 4<<20)
+	createZipEntries(t, archivePath, []testEntry{{name: "bomb.bin", content: content}})
+
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// размер сжатых данных в центральном каталоге завышается до размера
+	// распакованного файла, чтобы степень сжатия по заголовку была 1:1
+	central := bytes.LastIndex(data, []byte("PK\x01\x02"))
+	if central < 0 {
+		t.Fatal("Не найден центральный каталог")
+	}
+	binary.LittleEndian.PutUint32(data[central+20:], uint32(len(content)))
+	if err := os.WriteFile(archivePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = ExtractZipWithLimits(&mockLogger{}, archivePath, filepath.Join(tempDir, "dest"), Limits{MaxRatio: 10})
+	var limitErr *errors.ArchiveLimitError
+	if !stderrors.As(err, &limitErr) || limitErr.Limit != "степень сжатия" {
+		t.Fatalf("Ожидалось превышение степени сжатия, получено: %v", err)
+	}
+}
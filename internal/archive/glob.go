@@ -0,0 +1,127 @@
+package archive
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// expandPattern раскрывает шаблон пути. Помимо синтаксиса filepath.Glob
+// поддерживается сегмент "**", совпадающий с любым числом директорий:
+// "src/**/*.go", "./assets/**".
+func expandPattern(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+
+	segments := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	static := 0
+	for static < len(segments) && !hasMeta(segments[static]) {
+		static++
+	}
+	rest := segments[static:]
+
+	for _, seg := range rest {
+		if seg == "**" {
+			continue
+		}
+		if strings.Contains(seg, "**") {
+			return nil, filepath.ErrBadPattern
+		}
+		if _, err := filepath.Match(seg, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	base := filepath.FromSlash(strings.Join(segments[:static], "/"))
+	switch {
+	case base == "" && strings.HasPrefix(filepath.ToSlash(pattern), "/"):
+		base = string(filepath.Separator)
+	case base == "":
+		base = "."
+	}
+
+	var matches []string
+	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == base && os.IsNotExist(err) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if path == base {
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		if matchSegments(rest, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+type candidate struct {
+	path string
+	dir  bool
+}
+
+// walkDir возвращает все файлы внутри dir, а с emptyDirs — ещё и пустые
+// директории, чтобы они появились в архиве отдельными записями.
+func walkDir(dir string, emptyDirs bool) ([]candidate, error) {
+	var found []candidate
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			found = append(found, candidate{path: path})
+			return nil
+		}
+		if emptyDirs && isEmptyDir(path) {
+			found = append(found, candidate{path: path, dir: true})
+		}
+		return nil
+	})
+	return found, err
+}
+
+func isEmptyDir(path string) bool {
+	entries, err := os.ReadDir(path)
+	return err == nil && len(entries) == 0
+}
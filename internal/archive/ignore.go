@@ -0,0 +1,192 @@
+package archive
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const IgnoreFile = ".pmignore"
+
+// Matcher применяет исключения в духе .gitignore к путям относительно
+// корня архива:
+//   - шаблон без "/" сравнивается с именем на любой глубине ("*.tmp");
+//   - шаблон с "/" сравнивается с полным путём от корня ("build/**");
+//   - "/" в конце ограничивает шаблон директориями;
+//   - "!" в начале возвращает ранее исключённый путь;
+//   - побеждает последнее совпавшее правило, а исключённая директория
+//     исключает всё своё содержимое.
+type Matcher struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	source   string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+func NewMatcher(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, p := range patterns {
+		rule := ignoreRule{source: p}
+
+		if strings.HasPrefix(p, "!") {
+			rule.negate = true
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			rule.dirOnly = true
+			p = strings.TrimRight(p, "/")
+		}
+		p = strings.TrimPrefix(filepath.ToSlash(p), "./")
+		if p == "" {
+			continue
+		}
+
+		if strings.Contains(p, "/") {
+			rule.segments = strings.Split(strings.TrimPrefix(p, "/"), "/")
+		} else {
+			rule.segments = []string{"**", p}
+		}
+
+		for _, seg := range rule.segments {
+			if seg == "**" {
+				continue
+			}
+			if strings.Contains(seg, "**") {
+				return nil, filepath.ErrBadPattern
+			}
+			if _, err := filepath.Match(seg, ""); err != nil {
+				return nil, err
+			}
+		}
+
+		m.rules = append(m.rules, rule)
+	}
+	return m, nil
+}
+
+// Excluded сообщает, исключён ли путь rel, и возвращает решившее правило.
+func (m *Matcher) Excluded(rel string, isDir bool) (bool, string) {
+	if m == nil || len(m.rules) == 0 {
+		return false, ""
+	}
+
+	segments := strings.Split(strings.TrimPrefix(filepath.ToSlash(filepath.Clean(rel)), "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		if excluded, rule := m.match(segments[:i], true); excluded {
+			return true, rule
+		}
+	}
+	return m.match(segments, isDir)
+}
+
+func (m *Matcher) match(segments []string, isDir bool) (bool, string) {
+	excluded, source := false, ""
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		// "build/**" — содержимое build, но не сама директория
+		if n := len(rule.segments); rule.segments[n-1] == "**" && len(segments) < n {
+			continue
+		}
+		if matchSegments(rule.segments, segments) {
+			excluded, source = !rule.negate, rule.source
+		}
+	}
+	return excluded, source
+}
+
+// scopedMatcher — исключения, шаблоны которых отсчитываются от своей
+// директории base: .pmignore — от директории, где он лежит, exclude цели —
+// от корня архива.
+type scopedMatcher struct {
+	base    string
+	matcher *Matcher
+	// anchored — на пути вне base набор не действует. Иначе такие пути
+	// сравниваются целиком, как раньше для целей вне корня архива.
+	anchored bool
+}
+
+// excludedBy применяет наборы исключений к path по порядку так же, как
+// Matcher применяет правила: побеждает последнее совпавшее правило, а
+// исключённая директория исключает всё своё содержимое.
+func excludedBy(scopes []scopedMatcher, path string, isDir bool) (bool, string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+
+	segments := make([][]string, len(scopes))
+	depth := 0
+	for i, scope := range scopes {
+		if scope.matcher == nil || len(scope.matcher.rules) == 0 {
+			continue
+		}
+		base, err := filepath.Abs(scope.base)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(base, abs)
+		if err != nil || rel == "." || escapes(rel) {
+			if scope.anchored {
+				continue
+			}
+			rel = path
+		}
+		segments[i] = strings.Split(strings.TrimPrefix(filepath.ToSlash(filepath.Clean(rel)), "/"), "/")
+		if len(segments[i]) > depth {
+			depth = len(segments[i])
+		}
+	}
+
+	decide := func(up int, isDir bool) (bool, string) {
+		excluded, source := false, ""
+		for i, scope := range scopes {
+			if len(segments[i]) <= up {
+				continue
+			}
+			if e, s := scope.matcher.match(segments[i][:len(segments[i])-up], isDir); s != "" {
+				excluded, source = e, s
+			}
+		}
+		return excluded, source
+	}
+
+	// up — на сколько уровней выше path находится проверяемая директория
+	for up := depth - 1; up >= 1; up-- {
+		if excluded, rule := decide(up, true); excluded {
+			return true, rule
+		}
+	}
+	return decide(0, isDir)
+}
+
+// LoadIgnoreFile читает шаблоны из .pmignore: по одному на строку,
+// пустые строки и комментарии (#) пропускаются. Отсутствующий файл
+// означает пустой список.
+func LoadIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
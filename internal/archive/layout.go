@@ -0,0 +1,106 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"pm/config"
+)
+
+// Layout сопоставляет пути собранных файлов с именами записей архива для
+// целей с dest. Файлы без записи в Layout упаковываются относительно
+// корня архива.
+type Layout map[string]string
+
+func (l Layout) entryName(root, filePath string) (string, error) {
+	name, ok := l[filePath]
+	if !ok {
+		var err error
+		if name, err = entryName(root, filePath); err != nil {
+			return "", err
+		}
+	}
+	if isReserved(name) {
+		return "", fmt.Errorf("файл %s попадает в служебную директорию %s, зарезервированную за pm", filePath, ReservedDir)
+	}
+	return name, nil
+}
+
+// checkDuplicates не даёт двум файлам попасть в архив под одним именем,
+// а файлу — занять путь, который нужен другому как директория.
+func (l Layout) checkDuplicates(root string, files []string) error {
+	owners := make(map[string]string)
+	for _, f := range files {
+		name, ok := l[f]
+		if !ok {
+			name = filepath.ToSlash(relativeTo(root, f))
+		}
+		if other, ok := owners[name]; ok {
+			return fmt.Errorf("файлы %s и %s попадают в архив под одним именем %s", other, f, name)
+		}
+		owners[name] = f
+	}
+
+	for name, f := range owners {
+		for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if other, ok := owners[dir]; ok {
+				info, err := os.Stat(other)
+				if err == nil && info.IsDir() {
+					continue
+				}
+				return fmt.Errorf("файл %s попадает в архив как %s, но этот путь нужен как директория для %s", other, dir, f)
+			}
+		}
+	}
+	return nil
+}
+
+// destName возвращает имя записи для файла filePath цели t с dest: путь
+// файла относительно неизменной части шаблона, помещённый в dest.
+// Например, для ./build/*.so и dest lib/ файл build/libx.so станет
+// lib/libx.so.
+func destName(t config.Target, filePath string) (string, error) {
+	if !config.ValidDest(t.Dest) {
+		return "", fmt.Errorf("некорректный путь назначения %q", t.Dest)
+	}
+
+	rel, err := filepath.Rel(patternBase(t.Path), filePath)
+	if err != nil {
+		return "", err
+	}
+	if escapes(rel) {
+		return "", fmt.Errorf("файл %s находится вне %s", filePath, patternBase(t.Path))
+	}
+
+	name := path.Join(filepath.ToSlash(t.Dest), filepath.ToSlash(rel))
+	if name == "." {
+		return "", fmt.Errorf("файл %s не может занимать корень архива", filePath)
+	}
+	return strings.TrimPrefix(name, "./"), nil
+}
+
+// patternBase возвращает неизменную часть шаблона: директорию до первого
+// элемента с метасимволами, саму директорию или директорию файла.
+func patternBase(pattern string) string {
+	if !hasMeta(pattern) {
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			return filepath.Clean(pattern)
+		}
+		return filepath.Dir(pattern)
+	}
+
+	var base []string
+	for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if hasMeta(segment) {
+			break
+		}
+		base = append(base, segment)
+	}
+	if len(base) == 0 {
+		return "."
+	}
+	return filepath.Clean(filepath.FromSlash(strings.Join(base, "/")))
+}
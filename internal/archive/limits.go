@@ -0,0 +1,136 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+
+	"pm/internal/errors"
+)
+
+// Limits ограничивает ресурсы, которые может занять распаковка одного
+// архива. Нулевое значение поля означает отсутствие ограничения.
+type Limits struct {
+	MaxTotalSize int64
+	MaxFileSize  int64
+	MaxEntries   int
+	MaxRatio     float64
+}
+
+var DefaultLimits = Limits{
+	MaxTotalSize: 1 << 30,
+	MaxFileSize:  512 << 20,
+	MaxEntries:   100000,
+	MaxRatio:     200,
+}
+
+// ratioThreshold — объём, после которого проверяется степень сжатия:
+// маленькие файлы из одинаковых байтов легально сжимаются в сотни раз.
+const ratioThreshold = 1 << 20
+
+// budget отслеживает расход лимитов по мере распаковки: заявленным в
+// заголовках размерам не доверяем, считаем реально записанные байты.
+type budget struct {
+	limits  Limits
+	archive string
+	entries int
+	total   int64
+
+	// stream — архив сжат целиком (tar.gz), и степень сжатия считается
+	// по всему распакованному объёму, а не по отдельной записи.
+	stream bool
+}
+
+func newBudget(archivePath string, limits Limits, stream bool) *budget {
+	return &budget{limits: limits, archive: archivePath, stream: stream}
+}
+
+func (b *budget) addEntry(name string) error {
+	b.entries++
+	if b.limits.MaxEntries > 0 && b.entries > b.limits.MaxEntries {
+		return errors.NewArchiveLimitError(b.archive, name, "количество записей", fmt.Sprint(b.limits.MaxEntries))
+	}
+	return nil
+}
+
+// copy копирует содержимое записи name. compressed возвращает число сжатых
+// байтов, прочитанных к текущему моменту, — для zip это байты, прочитанные
+// из архива с начала записи, для tar.gz позиция в файле архива.
+func (b *budget) copy(dst io.Writer, src io.Reader, name string, compressed func() int64) error {
+	buf := make([]byte, 32*1024)
+	var written int64
+
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			written += int64(n)
+			b.total += int64(n)
+			if err := b.check(name, written, compressed()); err != nil {
+				return err
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+func (b *budget) check(name string, written, compressed int64) error {
+	if b.limits.MaxFileSize > 0 && written > b.limits.MaxFileSize {
+		return errors.NewArchiveLimitError(b.archive, name, "размер файла", formatSize(b.limits.MaxFileSize))
+	}
+	if b.limits.MaxTotalSize > 0 && b.total > b.limits.MaxTotalSize {
+		return errors.NewArchiveLimitError(b.archive, name, "суммарный размер", formatSize(b.limits.MaxTotalSize))
+	}
+	uncompressed := written
+	if b.stream {
+		uncompressed = b.total
+	}
+	if b.limits.MaxRatio > 0 && uncompressed > ratioThreshold && compressed > 0 &&
+		float64(uncompressed)/float64(compressed) > b.limits.MaxRatio {
+		return errors.NewArchiveLimitError(b.archive, name, "степень сжатия", fmt.Sprintf("%g:1", b.limits.MaxRatio))
+	}
+	return nil
+}
+
+// countingReader считает байты, прочитанные из сжатого потока.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingReaderAt считает байты, прочитанные из файла zip-архива.
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n += int64(n)
+	return n, err
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%dGiB", n>>30)
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dMiB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dKiB", n>>10)
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
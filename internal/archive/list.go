@@ -0,0 +1,107 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Files возвращает пути файлов и ссылок архива (без директорий) в том
+// виде, в котором они окажутся в директории назначения.
+func Files(archivePath, format string) ([]string, error) {
+	files, _, err := Contents(archivePath, format)
+	return files, err
+}
+
+// Contents возвращает пути файлов и ссылок архива и отдельно — пустые
+// директории, то есть записи директорий, внутри которых в архиве ничего нет.
+func Contents(archivePath, format string) ([]string, []string, error) {
+	var names, dirNames []string
+	switch format {
+	case "zip":
+		reader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer reader.Close()
+		for _, file := range reader.File {
+			if file.FileInfo().IsDir() {
+				dirNames = append(dirNames, file.Name)
+			} else {
+				names = append(names, file.Name)
+			}
+		}
+	case "tar.gz", "tgz":
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer file.Close()
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gzReader.Close()
+
+		tarReader := tar.NewReader(gzReader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			switch header.Typeflag {
+			case tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+				names = append(names, header.Name)
+			case tar.TypeDir:
+				dirNames = append(dirNames, header.Name)
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("неподдерживаемый формат архива: %s", format)
+	}
+
+	files := cleanNames(names)
+	all := cleanNames(dirNames)
+
+	// директория не пуста, если в архиве есть записи внутри неё
+	parents := make(map[string]bool)
+	for _, name := range append(append([]string{}, files...), all...) {
+		for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			parents[dir] = true
+		}
+	}
+	var dirs []string
+	for _, dir := range all {
+		if dir != "." && !parents[dir] {
+			dirs = append(dirs, dir)
+		}
+	}
+	return files, dirs, nil
+}
+
+// cleanNames нормализует имена записей, убирает повторы и описание пакета.
+func cleanNames(names []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, name := range names {
+		clean := path.Clean(strings.TrimPrefix(name, "./"))
+		if clean == ManifestFile {
+			continue
+		}
+		if !seen[clean] {
+			seen[clean] = true
+			result = append(result, clean)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
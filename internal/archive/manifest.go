@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ReservedDir — служебная директория pm в корне установки. Других записей
+// внутри неё, кроме ManifestFile, в архиве быть не может: распакованные,
+// они перезаписали бы базу установленных пакетов.
+const ReservedDir = ".pm"
+
+// ManifestFile — запись архива с описанием пакета (packet.json). Она не
+// распаковывается: по ней pm reindex восстанавливает зависимости пакета.
+const ManifestFile = ReservedDir + "/packet.json"
+
+// maxManifestSize ограничивает размер описания пакета при чтении.
+const maxManifestSize = 1 << 20
+
+func isManifest(name string) bool {
+	return path.Clean(strings.TrimPrefix(name, "./")) == ManifestFile
+}
+
+func isReserved(name string) bool {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	return clean == ReservedDir || strings.HasPrefix(clean, ReservedDir+"/")
+}
+
+// ReadManifest возвращает содержимое ManifestFile архива или nil, если
+// архив создан без описания пакета.
+func ReadManifest(archivePath, format string) ([]byte, error) {
+	switch format {
+	case "zip":
+		reader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		for _, file := range reader.File {
+			if !isManifest(file.Name) || file.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return readManifest(rc)
+		}
+		return nil, nil
+	case "tar.gz", "tgz":
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gzReader.Close()
+
+		tarReader := tar.NewReader(gzReader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if header.Typeflag == tar.TypeReg && isManifest(header.Name) {
+				return readManifest(tarReader)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("неподдерживаемый формат архива: %s", format)
+	}
+}
+
+func readManifest(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxManifestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxManifestSize {
+		return nil, fmt.Errorf("описание пакета %s больше %s", ManifestFile, formatSize(maxManifestSize))
+	}
+	return data, nil
+}
+
+func writeZipManifest(zw *zip.Writer, manifest []byte) error {
+	header := &zip.FileHeader{Name: ManifestFile, Method: zip.Deflate, Modified: time.Now()}
+	header.SetMode(0644)
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(manifest)
+	return err
+}
+
+func writeTarManifest(tw *tar.Writer, manifest []byte) error {
+	header := &tar.Header{
+		Name:     ManifestFile,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(manifest)),
+		ModTime:  time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(manifest)
+	return err
+}
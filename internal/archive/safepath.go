@@ -0,0 +1,136 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pm/internal/errors"
+)
+
+// entryPath возвращает путь, по которому запись name будет распакована
+// в destDir. Абсолютные пути, пути, выходящие за destDir через "..", и
+// записи в ReservedDir отклоняются, а не исправляются: такой архив почти
+// наверняка вредоносный.
+func entryPath(archivePath, destDir, name string) (string, error) {
+	if name == "" {
+		return "", errors.NewUnsafePathError(archivePath, name, "пустое имя записи")
+	}
+
+	native := filepath.FromSlash(name)
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(native) || filepath.VolumeName(native) != "" {
+		return "", errors.NewUnsafePathError(archivePath, name, "абсолютный путь")
+	}
+
+	clean := filepath.Clean(native)
+	if escapes(clean) {
+		return "", errors.NewUnsafePathError(archivePath, name, "путь выходит за пределы директории назначения")
+	}
+	if isReserved(filepath.ToSlash(clean)) {
+		return "", errors.NewUnsafePathError(archivePath, name, "служебная директория "+ReservedDir+" зарезервирована за pm")
+	}
+
+	return filepath.Join(destDir, clean), nil
+}
+
+// checkLinkTarget проверяет, что символическая ссылка linkPath -> target
+// указывает внутрь destDir. Путь разрешается по реальной файловой системе:
+// директория ссылки и компоненты цели сами могут оказаться ссылками,
+// созданными этим же архивом (d -> . и затем d/l -> ..).
+func checkLinkTarget(archivePath, destDir, name, linkPath, target string) error {
+	if target == "" {
+		return errors.NewUnsafePathError(archivePath, name, "пустая символическая ссылка")
+	}
+	if filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
+		return errors.NewUnsafePathError(archivePath, name, "символическая ссылка на абсолютный путь "+target)
+	}
+
+	outside := errors.NewUnsafePathError(archivePath, name, "символическая ссылка указывает за пределы директории назначения: "+target)
+	realDest, err := filepath.EvalSymlinks(destDir)
+	if err != nil {
+		return errors.NewUnsafePathError(archivePath, name, err.Error())
+	}
+	current, err := filepath.EvalSymlinks(filepath.Dir(linkPath))
+	if err != nil {
+		return outside
+	}
+
+	for _, part := range strings.Split(filepath.FromSlash(target), string(filepath.Separator)) {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+			continue
+		}
+		current = filepath.Join(current, part)
+
+		info, err := os.Lstat(current)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if current, err = filepath.EvalSymlinks(current); err != nil {
+			return outside
+		}
+	}
+
+	rel, err := filepath.Rel(realDest, current)
+	if err != nil || escapes(rel) {
+		return outside
+	}
+	return nil
+}
+
+// checkParents не даёт записать файл через символическую ссылку, уже
+// существующую в destDir (созданную этим же архивом или раньше) и
+// ведущую за его пределы.
+func checkParents(archivePath, destDir, name, path string) error {
+	rel, err := filepath.Rel(destDir, path)
+	if err != nil {
+		return errors.NewUnsafePathError(archivePath, name, err.Error())
+	}
+
+	realDest, err := filepath.EvalSymlinks(destDir)
+	if err != nil {
+		// Директории назначения ещё нет — значит, нет и ссылок внутри неё
+		return nil
+	}
+
+	current := destDir
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+
+		info, err := os.Lstat(current)
+		if err != nil {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		resolved, err := filepath.EvalSymlinks(current)
+		if err != nil {
+			return errors.NewUnsafePathError(archivePath, name, "битая символическая ссылка в пути: "+current)
+		}
+		inner, err := filepath.Rel(realDest, resolved)
+		if err != nil || escapes(inner) {
+			return errors.NewUnsafePathError(archivePath, name, "путь проходит через символическую ссылку за пределы директории назначения: "+current)
+		}
+	}
+	return nil
+}
+
+// removeSymlink удаляет существующую символическую ссылку на месте
+// распаковываемого файла, чтобы запись не ушла по ней за пределы destDir.
+func removeSymlink(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+func escapes(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
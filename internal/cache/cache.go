@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pm/internal/utils"
+)
+
+// Entry описывает архив в кэше. Время последнего использования хранится
+// как время изменения файла архива.
+type Entry struct {
+	Checksum string    `json:"sha256"`
+	Name     string    `json:"name"`
+	Version  string    `json:"version"`
+	File     string    `json:"file"`
+	Source   string    `json:"source,omitempty"`
+	Added    time.Time `json:"added"`
+	Size     int64     `json:"-"`
+	Used     time.Time `json:"-"`
+}
+
+// Cache — кэш скачанных архивов с адресацией по SHA-256: одинаковые архивы
+// из разных проектов и репозиториев хранятся один раз.
+//
+//	<dir>/sha256/<checksum>       архив
+//	<dir>/sha256/<checksum>.json  описание
+//	<dir>/tmp/                    незавершённые загрузки
+type Cache struct {
+	dir     string
+	maxSize int64
+}
+
+// DefaultDir возвращает ~/.cache/pm (или его аналог на текущей ОС).
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "pm-cache")
+	}
+	return filepath.Join(dir, "pm")
+}
+
+// Open открывает кэш в dir, создавая директории. maxSize ограничивает
+// суммарный размер архивов при вызове Evict; 0 — без ограничения.
+func Open(dir string, maxSize int64) (*Cache, error) {
+	for _, sub := range []string{"sha256", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Cache{dir: dir, maxSize: maxSize}, nil
+}
+
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+func (c *Cache) path(checksum string) string {
+	return filepath.Join(c.dir, "sha256", checksum)
+}
+
+// TempFile возвращает путь для скачивания архива. Файл создаётся внутри
+// кэша, чтобы Put мог переместить его на место без копирования.
+func (c *Cache) TempFile(name string) (string, error) {
+	f, err := os.CreateTemp(filepath.Join(c.dir, "tmp"), "*-"+filepath.Base(name))
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	return f.Name(), nil
+}
+
+// Get возвращает путь к архиву с контрольной суммой checksum. Содержимое
+// перепроверяется: повреждённый архив удаляется из кэша и считается
+// отсутствующим.
+func (c *Cache) Get(checksum string) (string, bool) {
+	if !validChecksum(checksum) {
+		return "", false
+	}
+
+	path := c.path(checksum)
+	actual, _, err := utils.FileSHA256(path)
+	if err != nil {
+		return "", false
+	}
+	if actual != checksum {
+		c.Remove(checksum)
+		return "", false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return path, true
+}
+
+// Put перемещает скачанный и проверенный архив src в кэш и возвращает
+// его новый путь.
+func (c *Cache) Put(src string, e Entry) (string, error) {
+	if !validChecksum(e.Checksum) {
+		return "", fmt.Errorf("некорректная контрольная сумма %q", e.Checksum)
+	}
+	if e.Added.IsZero() {
+		e.Added = time.Now().UTC()
+	}
+
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := c.path(e.Checksum)
+	if err := os.WriteFile(path+".json", data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(src, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (c *Cache) Remove(checksum string) error {
+	path := c.path(checksum)
+	err := os.Remove(path)
+	os.Remove(path + ".json")
+	if err != nil && !stderrors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List возвращает архивы в кэше, начиная с давно не использованных.
+func (c *Cache) List() ([]Entry, error) {
+	files, err := os.ReadDir(filepath.Join(c.dir, "sha256"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, f := range files {
+		if f.IsDir() || !validChecksum(f.Name()) {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+
+		e := Entry{Checksum: f.Name()}
+		if data, err := os.ReadFile(c.path(f.Name()) + ".json"); err == nil {
+			json.Unmarshal(data, &e)
+		}
+		e.Checksum = f.Name()
+		e.Size = info.Size()
+		e.Used = info.ModTime()
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Used.Before(entries[j].Used) })
+	return entries, nil
+}
+
+// Verify пересчитывает контрольные суммы всех архивов и удаляет
+// повреждённые. Возвращает удалённые записи.
+func (c *Cache) Verify() ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var corrupted []Entry
+	for _, e := range entries {
+		actual, _, err := utils.FileSHA256(c.path(e.Checksum))
+		if err == nil && actual == e.Checksum {
+			continue
+		}
+		if err := c.Remove(e.Checksum); err != nil {
+			return corrupted, err
+		}
+		corrupted = append(corrupted, e)
+	}
+	return corrupted, nil
+}
+
+// Clean удаляет из кэша все архивы и незавершённые загрузки.
+func (c *Cache) Clean() ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if err := c.Remove(e.Checksum); err != nil {
+			return nil, err
+		}
+	}
+	return entries, c.cleanTemp(0)
+}
+
+// Evict удаляет давно не использованные архивы, пока суммарный размер
+// кэша превышает ограничение, а также брошенные незавершённые загрузки.
+func (c *Cache) Evict() ([]Entry, error) {
+	if err := c.cleanTemp(24 * time.Hour); err != nil {
+		return nil, err
+	}
+	if c.maxSize <= 0 {
+		return nil, nil
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	var evicted []Entry
+	for _, e := range entries {
+		if total <= c.maxSize {
+			break
+		}
+		if err := c.Remove(e.Checksum); err != nil {
+			return evicted, err
+		}
+		total -= e.Size
+		evicted = append(evicted, e)
+	}
+	return evicted, nil
+}
+
+// cleanTemp удаляет незавершённые загрузки старше age: более свежие могут
+// принадлежать параллельно работающему pm.
+func (c *Cache) cleanTemp(age time.Duration) error {
+	dir := filepath.Join(c.dir, "tmp")
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil || time.Since(info.ModTime()) < age {
+			continue
+		}
+		os.Remove(filepath.Join(dir, f.Name()))
+	}
+	return nil
+}
+
+func validChecksum(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	return strings.Trim(s, "0123456789abcdef") == ""
+}
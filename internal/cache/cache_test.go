@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pm/internal/utils"
+)
+
+// putArchive кладёт в кэш архив с содержимым content и возвращает его
+// контрольную сумму.
+func putArchive(t *testing.T, c *Cache, name, content string) string {
+	t.Helper()
+	tmp, err := c.TempFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checksum, _, err := utils.FileSHA256(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Put(tmp, Entry{Checksum: checksum, Name: name, File: name}); err != nil {
+		t.Fatalf("Ошибка Put: %v", err)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("Временный файл %s должен быть перемещён", tmp)
+	}
+	return checksum
+}
+
+func TestGetPut(t *testing.T) {
+	c, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checksum := putArchive(t, c, "app-1.0.zip", "содержимое")
+
+	path, ok := c.Get(checksum)
+	if !ok {
+		t.Fatal("Архив не найден в кэше")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "содержимое" {
+		t.Errorf("Содержимое не совпадает: %q", data)
+	}
+
+	if _, ok := c.Get("0000000000000000000000000000000000000000000000000000000000000000"); ok {
+		t.Error("Найден отсутствующий архив")
+	}
+	if _, ok := c.Get("../../etc/passwd"); ok {
+		t.Error("Найден архив по некорректной контрольной сумме")
+	}
+
+	entries, err := c.List()
+	if err != nil || len(entries) != 1 || entries[0].Name != "app-1.0.zip" {
+		t.Fatalf("List() = %v, %v", entries, err)
+	}
+
+	// повреждённый архив не отдаётся и удаляется
+	if err := os.WriteFile(path, []byte("подмена"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(checksum); ok {
+		t.Error("Повреждённый архив отдан из кэша")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Повреждённый архив не удалён")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	c, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good := putArchive(t, c, "good.zip", "good")
+	bad := putArchive(t, c, "bad.zip", "bad")
+	if err := os.WriteFile(filepath.Join(c.Dir(), "sha256", bad), []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupted, err := c.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrupted) != 1 || corrupted[0].Checksum != bad {
+		t.Errorf("Verify() = %v, ожидался только %s", corrupted, bad)
+	}
+	if _, ok := c.Get(good); !ok {
+		t.Error("Целый архив удалён")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	c, err := Open(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := putArchive(t, c, "old.zip", "123456")
+	recent := putArchive(t, c, "recent.zip", "abcdef")
+
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(c.Dir(), "sha256", old), past, past)
+
+	evicted, err := c.Evict()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 1 || evicted[0].Checksum != old {
+		t.Fatalf("Evict() = %v, ожидался давно не использованный %s", evicted, old)
+	}
+	if _, ok := c.Get(recent); !ok {
+		t.Error("Недавно использованный архив удалён")
+	}
+
+	removed, err := c.Clean()
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("Clean() = %v, %v", removed, err)
+	}
+	if entries, _ := c.List(); len(entries) != 0 {
+		t.Errorf("После Clean в кэше остались архивы: %v", entries)
+	}
+}
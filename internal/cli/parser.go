@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
@@ -12,14 +13,67 @@ import (
 type CommandType string
 
 const (
-	Create CommandType = "create"
-	Update CommandType = "update"
+	Create  CommandType = "create"
+	Update  CommandType = "update"
+	Reindex CommandType = "reindex"
+	Lock    CommandType = "lock"
+	Keygen  CommandType = "keygen"
+	Sign    CommandType = "sign"
+	Verify  CommandType = "verify"
+	Lint    CommandType = "lint"
+	List    CommandType = "list"
+	Info    CommandType = "info"
+	Remove  CommandType = "remove"
+
+	ConfigGet  CommandType = "config get"
+	ConfigSet  CommandType = "config set"
+	ConfigList CommandType = "config list"
+
+	CacheList   CommandType = "cache list"
+	CacheClean  CommandType = "cache clean"
+	CacheVerify CommandType = "cache verify"
+)
+
+// ConfigScope — файл настроек, в который пишет pm config set.
+type ConfigScope string
+
+const (
+	ScopeUser    ConfigScope = "user"
+	ScopeProject ConfigScope = "project"
+	ScopeSystem  ConfigScope = "system"
 )
 
 type ParsedCommand struct {
-	Type       CommandType
-	ConfigPath string
-	LogLevel   string
+	Type         CommandType
+	ConfigPath   string
+	LogLevel     string
+	Profile      string
+	Overrides    map[string]string
+	Root         string
+	PreferLowest bool
+	Frozen       bool
+
+	RequireSignatures bool
+	// Лимиты распаковки из флагов; nil — флаг не задан и действует
+	// параметр limits.* из настроек.
+	MaxTotalSize *int64
+	MaxFileSize  *int64
+	MaxEntries   *int
+	MaxRatio     *float64
+
+	ArchivePath     string
+	KeyPath         string
+	KeyComment      string
+	SignaturePath   string
+	TrustedKeysPath string
+
+	SettingKey   string
+	SettingValue string
+	SettingScope ConfigScope
+	ShowOrigin   bool
+
+	PackageName string
+	Force       bool
 }
 
 func Parse() (*ParsedCommand, error) {
@@ -30,12 +84,75 @@ func Parse() (*ParsedCommand, error) {
 	logLevel := app.Flag("log-level", "Уровень логирования").
 		Default("info").
 		Enum("debug", "info", "warn", "error")
+	profile := app.Flag("profile", "Профиль настроек (по умолчанию PM_PROFILE или параметр profile)").String()
+	overrides := app.Flag("option", "Переопределить параметр настроек: -c ключ=значение").Short('c').StringMap()
+	var rootSet, prefixSet bool
+	root := app.Flag("root", "Корневая директория установки пакетов").
+		Envar("PM_ROOT").Default(".").IsSetByUser(&rootSet).String()
+	prefix := app.Flag("prefix", "То же, что --root").IsSetByUser(&prefixSet).String()
 
 	createCmd := app.Command(string(Create), "Упаковать файлы в архив")
 	createConfig := createCmd.Arg("config", "Путь к packet.json или packet.yaml").Required().ExistingFile()
 
 	updateCmd := app.Command(string(Update), "Скачать и распаковать пакеты")
 	updateConfig := updateCmd.Arg("config", "Путь к packages.json").Required().ExistingFile()
+	preferLowest := updateCmd.Flag("prefer-lowest", "Выбирать наименьшую версию, удовлетворяющую условию").Bool()
+	frozen := updateCmd.Flag("frozen", "Установить версии строго из pm.lock; ошибка, если он устарел").Bool()
+	requireSignatures := updateCmd.Flag("require-signatures", "Отказываться от установки неподписанных пакетов").Bool()
+	var maxTotalSizeSet, maxFileSizeSet, maxEntriesSet, maxRatioSet bool
+	maxTotalSize := updateCmd.Flag("max-size", "Максимальный суммарный размер распакованного архива (0 — без ограничения; по умолчанию limits.max_size)").
+		IsSetByUser(&maxTotalSizeSet).Bytes()
+	maxFileSize := updateCmd.Flag("max-file-size", "Максимальный размер одного распакованного файла (0 — без ограничения; по умолчанию limits.max_file_size)").
+		IsSetByUser(&maxFileSizeSet).Bytes()
+	maxEntries := updateCmd.Flag("max-entries", "Максимальное количество записей в архиве (0 — без ограничения; по умолчанию limits.max_entries)").
+		IsSetByUser(&maxEntriesSet).Int()
+	maxRatio := updateCmd.Flag("max-ratio", "Максимальная степень сжатия (0 — без ограничения; по умолчанию limits.max_ratio)").
+		IsSetByUser(&maxRatioSet).Float64()
+
+	lockCmd := app.Command(string(Lock), "Разрешить зависимости и обновить pm.lock без установки")
+	lockConfig := lockCmd.Arg("config", "Путь к packages.json").Required().ExistingFile()
+	lockPreferLowest := lockCmd.Flag("prefer-lowest", "Выбирать наименьшую версию, удовлетворяющую условию").Bool()
+
+	app.Command(string(Reindex), "Перестроить индекс репозитория по его содержимому")
+
+	keygenCmd := app.Command(string(Keygen), "Создать пару ключей ed25519 для подписи пакетов")
+	keygenOutput := keygenCmd.Flag("output", "Путь к закрытому ключу; открытый сохраняется рядом с расширением .pub").Short('o').String()
+	keygenComment := keygenCmd.Flag("comment", "Комментарий к ключу").Short('C').String()
+
+	signCmd := app.Command(string(Sign), "Подписать архив, создав рядом файл .sig")
+	signArchive := signCmd.Arg("archive", "Путь к архиву").Required().ExistingFile()
+	signKey := signCmd.Flag("key", "Путь к закрытому ключу (по умолчанию PM_SIGNING_KEY)").String()
+
+	verifyCmd := app.Command(string(Verify), "Проверить подпись архива")
+	verifyArchive := verifyCmd.Arg("archive", "Путь к архиву").Required().ExistingFile()
+	verifySignature := verifyCmd.Flag("signature", "Путь к файлу подписи (по умолчанию <архив>.sig)").String()
+	verifyTrusted := verifyCmd.Flag("trusted-keys", "Файл доверенных ключей (по умолчанию PM_TRUSTED_KEYS)").String()
+
+	lintCmd := app.Command(string(Lint), "Проверить packet.json или packages.json без обращения к репозиторию")
+	lintConfig := lintCmd.Arg("config", "Путь к файлу конфигурации").Required().ExistingFile()
+
+	app.Command(string(List), "Вывести установленные пакеты")
+	infoCmd := app.Command(string(Info), "Показать сведения об установленном пакете и его файлы")
+	infoName := infoCmd.Arg("name", "Имя пакета").Required().String()
+	removeCmd := app.Command(string(Remove), "Удалить файлы установленного пакета")
+	removeName := removeCmd.Arg("name", "Имя пакета").Required().String()
+	removeForce := removeCmd.Flag("force", "Удалить, даже если от пакета зависят другие установленные пакеты").Bool()
+
+	configCmd := app.Command("config", "Просмотреть и изменить настройки pm")
+	configGetCmd := configCmd.Command("get", "Вывести значение параметра")
+	configGetKey := configGetCmd.Arg("key", "Имя параметра, например ssh.port").Required().String()
+	configGetOrigin := configGetCmd.Flag("show-origin", "Показать, откуда взято значение").Bool()
+	configSetCmd := configCmd.Command("set", "Записать значение параметра в файл настроек")
+	configSetKey := configSetCmd.Arg("key", "Имя параметра, например ssh.port").Required().String()
+	configSetValue := configSetCmd.Arg("value", "Значение").Required().String()
+	configSetProject := configSetCmd.Flag("project", "Записать в pm.yaml текущей директории").Bool()
+	configSetSystem := configSetCmd.Flag("system", "Записать в системный файл").Bool()
+	configCmd.Command("list", "Вывести итоговые настройки и их источники")
+
+	cacheCmd := app.Command("cache", "Управление кэшем скачанных архивов")
+	cacheCmd.Command("list", "Вывести архивы в кэше")
+	cacheCmd.Command("clean", "Удалить все архивы из кэша")
+	cacheCmd.Command("verify", "Проверить контрольные суммы архивов и удалить повреждённые")
 
 	cmd, err := app.Parse(os.Args[1:])
 	if err != nil {
@@ -44,23 +161,145 @@ func Parse() (*ParsedCommand, error) {
 
 	normalizedLevel := strings.ToLower(*logLevel)
 
+	var parsed *ParsedCommand
 	switch cmd {
 	case string(Create):
-		return &ParsedCommand{
+		parsed = &ParsedCommand{
 			Type:       Create,
 			ConfigPath: *createConfig,
 			LogLevel:   normalizedLevel,
-		}, nil
+		}
 	case string(Update):
-		return &ParsedCommand{
-			Type:       Update,
-			ConfigPath: *updateConfig,
+		parsed = &ParsedCommand{
+			Type:         Update,
+			ConfigPath:   *updateConfig,
+			LogLevel:     normalizedLevel,
+			PreferLowest: *preferLowest,
+			Frozen:       *frozen,
+
+			RequireSignatures: *requireSignatures,
+		}
+		if maxTotalSizeSet {
+			size := int64(*maxTotalSize)
+			parsed.MaxTotalSize = &size
+		}
+		if maxFileSizeSet {
+			size := int64(*maxFileSize)
+			parsed.MaxFileSize = &size
+		}
+		if maxEntriesSet {
+			parsed.MaxEntries = maxEntries
+		}
+		if maxRatioSet {
+			parsed.MaxRatio = maxRatio
+		}
+	case string(Lock):
+		parsed = &ParsedCommand{
+			Type:         Lock,
+			ConfigPath:   *lockConfig,
+			LogLevel:     normalizedLevel,
+			PreferLowest: *lockPreferLowest,
+		}
+	case string(Reindex):
+		parsed = &ParsedCommand{
+			Type:     Reindex,
+			LogLevel: normalizedLevel,
+		}
+	case string(Keygen):
+		parsed = &ParsedCommand{
+			Type:       Keygen,
+			LogLevel:   normalizedLevel,
+			KeyPath:    *keygenOutput,
+			KeyComment: *keygenComment,
+		}
+	case string(Sign):
+		parsed = &ParsedCommand{
+			Type:        Sign,
+			LogLevel:    normalizedLevel,
+			ArchivePath: *signArchive,
+			KeyPath:     *signKey,
+		}
+	case string(Verify):
+		parsed = &ParsedCommand{
+			Type:            Verify,
+			LogLevel:        normalizedLevel,
+			ArchivePath:     *verifyArchive,
+			SignaturePath:   *verifySignature,
+			TrustedKeysPath: *verifyTrusted,
+		}
+	case string(Lint):
+		parsed = &ParsedCommand{
+			Type:       Lint,
+			ConfigPath: *lintConfig,
+			LogLevel:   normalizedLevel,
+		}
+	case string(List):
+		parsed = &ParsedCommand{
+			Type:     List,
+			LogLevel: normalizedLevel,
+		}
+	case string(Info):
+		parsed = &ParsedCommand{
+			Type:        Info,
+			LogLevel:    normalizedLevel,
+			PackageName: *infoName,
+		}
+	case string(Remove):
+		parsed = &ParsedCommand{
+			Type:        Remove,
+			LogLevel:    normalizedLevel,
+			PackageName: *removeName,
+			Force:       *removeForce,
+		}
+	case string(ConfigGet):
+		parsed = &ParsedCommand{
+			Type:       ConfigGet,
 			LogLevel:   normalizedLevel,
-		}, nil
+			SettingKey: *configGetKey,
+			ShowOrigin: *configGetOrigin,
+		}
+	case string(ConfigSet):
+		scope := ScopeUser
+		switch {
+		case *configSetProject && *configSetSystem:
+			return nil, fmt.Errorf("флаги --project и --system нельзя указывать вместе")
+		case *configSetProject:
+			scope = ScopeProject
+		case *configSetSystem:
+			scope = ScopeSystem
+		}
+		parsed = &ParsedCommand{
+			Type:         ConfigSet,
+			LogLevel:     normalizedLevel,
+			SettingKey:   *configSetKey,
+			SettingValue: *configSetValue,
+			SettingScope: scope,
+		}
+	case string(ConfigList):
+		parsed = &ParsedCommand{
+			Type:     ConfigList,
+			LogLevel: normalizedLevel,
+		}
+	case string(CacheList), string(CacheClean), string(CacheVerify):
+		parsed = &ParsedCommand{
+			Type:     CommandType(cmd),
+			LogLevel: normalizedLevel,
+		}
 	default:
 		if cmd == "" {
 			return nil, errors.ErrUnknownCommand
 		}
 		return nil, &errors.UnknownCommandError{Command: cmd}
 	}
+
+	parsed.Profile = *profile
+	parsed.Overrides = *overrides
+	parsed.Root = *root
+	if prefixSet {
+		if rootSet && *root != *prefix {
+			return nil, fmt.Errorf("флаги --root и --prefix задают разные корни: %s и %s", *root, *prefix)
+		}
+		parsed.Root = *prefix
+	}
+	return parsed, nil
 }
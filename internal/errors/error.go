@@ -8,6 +8,10 @@ var (
 	ErrEmptyFileList    = fmt.Errorf("список файлов пуст")
 	ErrInvalidSSHConfig = fmt.Errorf("некорректная конфигурация SSH")
 	ErrUnknownCommand   = fmt.Errorf("отсутствует команда")
+	ErrNoRepository     = fmt.Errorf("репозиторий не задан: укажите параметр repository или ssh.host")
+	ErrIndexNotFound    = fmt.Errorf("индекс репозитория не найден")
+	ErrIndexLocked      = fmt.Errorf("индекс репозитория заблокирован другой публикацией")
+	ErrUnsignedPackage  = fmt.Errorf("пакет не подписан")
 )
 
 type UnknownCommandError struct {
@@ -87,6 +91,37 @@ func NewArchiveExtractionError(zipPath, destDir string, err error) error {
 	return &ArchiveExtractionError{ZipPath: zipPath, DestDir: destDir, Err: err}
 }
 
+// UnsafePathError — запись архива, распаковка которой затронула бы файлы
+// за пределами директории назначения.
+type UnsafePathError struct {
+	Archive string
+	Entry   string
+	Reason  string
+}
+
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("небезопасная запись %q в архиве %q: %s", e.Entry, e.Archive, e.Reason)
+}
+
+func NewUnsafePathError(archive, entry, reason string) error {
+	return &UnsafePathError{Archive: archive, Entry: entry, Reason: reason}
+}
+
+type ArchiveLimitError struct {
+	Archive string
+	Entry   string
+	Limit   string
+	Max     string
+}
+
+func (e *ArchiveLimitError) Error() string {
+	return fmt.Sprintf("архив %q: превышен лимит «%s» (%s) на записи %q", e.Archive, e.Limit, e.Max, e.Entry)
+}
+
+func NewArchiveLimitError(archive, entry, limit, max string) error {
+	return &ArchiveLimitError{Archive: archive, Entry: entry, Limit: limit, Max: max}
+}
+
 type SSHConnectionError struct {
 	Server string
 	Err    error
@@ -155,3 +190,204 @@ func NewVersionError(version, constraint string, err error) error {
 		Err:        err,
 	}
 }
+
+type HostKeyMismatchError struct {
+	Host        string
+	Fingerprint string
+	File        string
+	Line        int
+	Err         error
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("ключ хоста %q не совпадает с записью %s:%d (получен %s): возможна атака посредника", e.Host, e.File, e.Line, e.Fingerprint)
+}
+
+func (e *HostKeyMismatchError) Unwrap() error {
+	return e.Err
+}
+
+func NewHostKeyMismatchError(host, fingerprint, file string, line int, err error) error {
+	return &HostKeyMismatchError{
+		Host:        host,
+		Fingerprint: fingerprint,
+		File:        file,
+		Line:        line,
+		Err:         err,
+	}
+}
+
+type UnknownHostKeyError struct {
+	Host           string
+	Fingerprint    string
+	KnownHostsFile string
+}
+
+func (e *UnknownHostKeyError) Error() string {
+	return fmt.Sprintf("хост %q (%s) отсутствует в %s", e.Host, e.Fingerprint, e.KnownHostsFile)
+}
+
+func NewUnknownHostKeyError(host, fingerprint, knownHostsFile string) error {
+	return &UnknownHostKeyError{
+		Host:           host,
+		Fingerprint:    fingerprint,
+		KnownHostsFile: knownHostsFile,
+	}
+}
+
+var ErrReadOnlyRepository = fmt.Errorf("репозиторий доступен только для чтения")
+
+type RepositoryError struct {
+	Repository string
+	Name       string
+	Err        error
+}
+
+func (e *RepositoryError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("ошибка репозитория %q", e.Repository)
+	}
+	return fmt.Sprintf("ошибка репозитория %q для %q", e.Repository, e.Name)
+}
+
+func (e *RepositoryError) Unwrap() error {
+	return e.Err
+}
+
+func NewRepositoryError(repository, name string, err error) error {
+	return &RepositoryError{Repository: repository, Name: name, Err: err}
+}
+
+type DependencyConflictError struct {
+	Package      string
+	Requirements []string
+	Available    []string
+}
+
+func (e *DependencyConflictError) Error() string {
+	msg := fmt.Sprintf("не удалось подобрать версию пакета %q", e.Package)
+	for _, r := range e.Requirements {
+		msg += "\n  - " + r
+	}
+	if len(e.Available) == 0 {
+		msg += "\n  пакет отсутствует в репозитории"
+	} else {
+		msg += fmt.Sprintf("\n  доступные версии: %v", e.Available)
+	}
+	return msg
+}
+
+func NewDependencyConflictError(pkg string, requirements, available []string) error {
+	return &DependencyConflictError{
+		Package:      pkg,
+		Requirements: requirements,
+		Available:    available,
+	}
+}
+
+type DependencyCycleError struct {
+	Cycle []string
+}
+
+func (e *DependencyCycleError) Error() string {
+	msg := "обнаружена циклическая зависимость: "
+	for i, p := range e.Cycle {
+		if i > 0 {
+			msg += " -> "
+		}
+		msg += p
+	}
+	return msg
+}
+
+func NewDependencyCycleError(cycle []string) error {
+	return &DependencyCycleError{Cycle: cycle}
+}
+
+var ErrLockNotFound = fmt.Errorf("lock-файл не найден: выполните pm lock")
+
+type LockOutdatedError struct {
+	Problems []string
+}
+
+func (e *LockOutdatedError) Error() string {
+	msg := "lock-файл устарел, выполните pm lock"
+	for _, p := range e.Problems {
+		msg += "\n  - " + p
+	}
+	return msg
+}
+
+func NewLockOutdatedError(problems []string) error {
+	return &LockOutdatedError{Problems: problems}
+}
+
+type IntegrityError struct {
+	File     string
+	Expected string
+	Actual   string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("контрольная сумма %q не совпадает: ожидалась %s, получена %s", e.File, e.Expected, e.Actual)
+}
+
+func NewIntegrityError(file, expected, actual string) error {
+	return &IntegrityError{File: file, Expected: expected, Actual: actual}
+}
+
+type SignatureError struct {
+	File string
+	Err  error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("ошибка проверки подписи %q: %v", e.File, e.Err)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+func NewSignatureError(file string, err error) error {
+	return &SignatureError{File: file, Err: err}
+}
+
+type ConfigProblem struct {
+	Line    int
+	Column  int
+	Field   string
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	msg := p.Message
+	if p.Field != "" {
+		msg = p.Field + ": " + msg
+	}
+	if p.Line > 0 {
+		return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, msg)
+	}
+	return msg
+}
+
+type ConfigValidationError struct {
+	File     string
+	Problems []ConfigProblem
+}
+
+func (e *ConfigValidationError) Error() string {
+	msg := fmt.Sprintf("некорректная конфигурация %s (ошибок: %d)", e.File, len(e.Problems))
+	for _, p := range e.Problems {
+		if p.Line > 0 {
+			msg += "\n  " + e.File + ":" + p.String()
+		} else {
+			msg += "\n  " + e.File + ": " + p.String()
+		}
+	}
+	return msg
+}
+
+func NewConfigValidationError(file string, problems []ConfigProblem) error {
+	return &ConfigValidationError{File: file, Problems: problems}
+}
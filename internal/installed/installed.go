@@ -0,0 +1,370 @@
+package installed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"pm/internal/repository"
+	"pm/internal/utils"
+)
+
+const (
+	Dir           = ".pm"
+	FileName      = "installed.json"
+	formatVersion = 1
+)
+
+// File — файл, распакованный из пакета. Для символических ссылок вместо
+// контрольной суммы хранится цель ссылки, для директорий, созданных
+// установкой, — только путь.
+type File struct {
+	Path     string `json:"path"`
+	Checksum string `json:"sha256,omitempty"`
+	Link     string `json:"link,omitempty"`
+	Dir      bool   `json:"dir,omitempty"`
+}
+
+type Package struct {
+	Name         string                  `json:"name"`
+	Version      string                  `json:"version"`
+	Format       string                  `json:"format"`
+	File         string                  `json:"file"`
+	Source       string                  `json:"source"`
+	Checksum     string                  `json:"sha256,omitempty"`
+	Dependencies []repository.Dependency `json:"dependencies,omitempty"`
+	// Dest — директория пакета относительно корня установки.
+	Dest      string    `json:"dest,omitempty"`
+	Installed time.Time `json:"installed"`
+	Files     []File    `json:"files"`
+}
+
+// DB — сведения об установленных пакетах, хранятся в
+// <корень>/.pm/installed.json.
+type DB struct {
+	Version  int       `json:"version"`
+	Packages []Package `json:"packages"`
+}
+
+// PathFor возвращает путь к базе установленных пакетов для корня root.
+func PathFor(root string) string {
+	return filepath.Join(root, Dir, FileName)
+}
+
+// Load читает базу; отсутствующий файл означает, что ничего не установлено.
+func Load(path string) (*DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &DB{Version: formatVersion}, nil
+		}
+		return nil, err
+	}
+
+	var db DB
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("повреждённый %s: %w", path, err)
+	}
+	if db.Version != formatVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия формата %s: %d", path, db.Version)
+	}
+	return &db, nil
+}
+
+func Save(path string, db *DB) error {
+	sort.Slice(db.Packages, func(i, j int) bool {
+		return db.Packages[i].Name < db.Packages[j].Name
+	})
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(db); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (db *DB) Find(name string) (Package, bool) {
+	for _, p := range db.Packages {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Package{}, false
+}
+
+// Put добавляет пакет или заменяет ранее установленную версию.
+func (db *DB) Put(p Package) {
+	for i := range db.Packages {
+		if db.Packages[i].Name == p.Name {
+			db.Packages[i] = p
+			return
+		}
+	}
+	db.Packages = append(db.Packages, p)
+}
+
+func (db *DB) Remove(name string) bool {
+	for i, p := range db.Packages {
+		if p.Name == name {
+			db.Packages = append(db.Packages[:i], db.Packages[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Describe собирает описание файлов пакета, распакованных в root.
+// files — пути из архива относительно root.
+func Describe(root string, files []string) ([]File, error) {
+	result := make([]File, 0, len(files))
+	for _, name := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		f := File{Path: name}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if f.Link, err = os.Readlink(path); err != nil {
+				return nil, err
+			}
+		} else if f.Checksum, _, err = utils.FileSHA256(path); err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+// CreatedDirs возвращает директории, которые нужны для файлов files и
+// пустых директорий dirs и которых нет в root или которые создал pm для
+// других пакетов. Директории, существовавшие до установки, в список не
+// попадают, и pm remove их не удаляет.
+func (db *DB) CreatedDirs(root string, files, dirs []string) ([]string, error) {
+	owned := make(map[string]bool)
+	for _, p := range db.Packages {
+		for _, f := range p.Files {
+			if f.Dir {
+				owned[f.Path] = true
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var candidates []string
+	add := func(dir string) {
+		for ; dir != "." && dir != "/" && dir != "" && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+			candidates = append(candidates, dir)
+		}
+	}
+	for _, name := range files {
+		add(path.Dir(name))
+	}
+	for _, dir := range dirs {
+		add(dir)
+	}
+	sort.Strings(candidates)
+
+	var result []string
+	for _, dir := range candidates {
+		if !owned[dir] {
+			_, err := os.Lstat(filepath.Join(root, filepath.FromSlash(dir)))
+			if err == nil {
+				continue
+			}
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		result = append(result, dir)
+	}
+	return result, nil
+}
+
+// Dependents возвращает установленные пакеты, зависящие от name.
+func (db *DB) Dependents(name string) []Package {
+	var result []Package
+	for _, p := range db.Packages {
+		for _, dep := range p.Dependencies {
+			if dep.Name == name {
+				result = append(result, p)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// UninstallResult — итог удаления файлов пакета. Пути указаны так же,
+// как в File.Path.
+type UninstallResult struct {
+	Removed []string
+	// Modified — файлы, изменённые после установки; они не удаляются.
+	Modified []string
+	// Shared — файлы, которые принадлежат и другим установленным пакетам.
+	Shared []string
+	// Missing — файлы, удалённые до pm remove.
+	Missing []string
+	// NotEmpty — директории пакета, в которых остались посторонние файлы.
+	NotEmpty []string
+}
+
+// Uninstall удаляет из root файлы пакета name, созданные им и опустевшие
+// после этого директории и запись о пакете в db. Изменённые после установки
+// файлы и файлы других пакетов остаются на месте.
+func Uninstall(root string, db *DB, name string) (UninstallResult, error) {
+	p, ok := db.Find(name)
+	if !ok {
+		return UninstallResult{}, fmt.Errorf("пакет %s не установлен", name)
+	}
+	db.Remove(name)
+	return removeFiles(root, db, p.Files, os.Remove)
+}
+
+// Replace записывает в db новую версию пакета и удаляет из root файлы
+// прежней версии, которых в новой нет, функцией remove.
+func Replace(root string, db *DB, p Package, remove func(string) error) (UninstallResult, error) {
+	old, ok := db.Find(p.Name)
+	db.Put(p)
+	if !ok {
+		return UninstallResult{}, nil
+	}
+
+	current := make(map[string]bool)
+	for _, f := range p.Files {
+		current[f.Path] = true
+	}
+	var stale []File
+	for _, f := range old.Files {
+		if !current[f.Path] {
+			stale = append(stale, f)
+		}
+	}
+	return removeFiles(root, db, stale, remove)
+}
+
+// removeFiles удаляет files, не принадлежащие пакетам из db. Директории
+// пакета удаляются после файлов и только если опустели.
+func removeFiles(root string, db *DB, files []File, remove func(string) error) (UninstallResult, error) {
+	var result UninstallResult
+
+	shared := make(map[string]bool)
+	for _, other := range db.Packages {
+		for _, f := range other.Files {
+			shared[f.Path] = true
+		}
+	}
+
+	// kept — оставленные на месте пути пакета: директория, в которой
+	// остались только они, не упоминается в NotEmpty ещё раз
+	kept := make(map[string]bool)
+	var pkgDirs []string
+	for _, f := range files {
+		if shared[f.Path] {
+			result.Shared = append(result.Shared, f.Path)
+			kept[f.Path] = true
+			continue
+		}
+		if f.Dir {
+			pkgDirs = append(pkgDirs, f.Path)
+			continue
+		}
+
+		path := filepath.Join(root, filepath.FromSlash(f.Path))
+		unchanged, err := unchanged(path, f)
+		if os.IsNotExist(err) {
+			result.Missing = append(result.Missing, f.Path)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		if !unchanged {
+			result.Modified = append(result.Modified, f.Path)
+			kept[f.Path] = true
+			continue
+		}
+
+		if err := remove(path); err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+		result.Removed = append(result.Removed, f.Path)
+	}
+
+	// вложенные директории удаляются раньше родительских
+	sort.Slice(pkgDirs, func(i, j int) bool { return len(pkgDirs[i]) > len(pkgDirs[j]) })
+	for _, dir := range pkgDirs {
+		path := filepath.Join(root, filepath.FromSlash(dir))
+		entries, err := os.ReadDir(path)
+		if os.IsNotExist(err) {
+			result.Missing = append(result.Missing, dir)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		if len(entries) > 0 {
+			if !onlyKept(dir, entries, kept) {
+				result.NotEmpty = append(result.NotEmpty, dir)
+			}
+			kept[dir] = true
+			continue
+		}
+
+		if err := remove(path); err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+		result.Removed = append(result.Removed, dir)
+	}
+
+	return result, nil
+}
+
+func onlyKept(dir string, entries []os.DirEntry, kept map[string]bool) bool {
+	for _, e := range entries {
+		if !kept[dir+"/"+e.Name()] {
+			return false
+		}
+	}
+	return true
+}
+
+// unchanged сообщает, совпадает ли файл на диске с записанным при установке.
+func unchanged(path string, f File) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+
+	if f.Link != "" {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return false, nil
+		}
+		target, err := os.Readlink(path)
+		return target == f.Link, err
+	}
+
+	if !info.Mode().IsRegular() {
+		return false, nil
+	}
+	checksum, _, err := utils.FileSHA256(path)
+	return checksum == f.Checksum, err
+}
@@ -0,0 +1,262 @@
+package installed
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"pm/internal/repository"
+)
+
+func TestLoadSave(t *testing.T) {
+	root := t.TempDir()
+	path := PathFor(root)
+
+	db, err := Load(path)
+	if err != nil {
+		t.Fatalf("Отсутствующая база должна читаться как пустая: %v", err)
+	}
+	if len(db.Packages) != 0 {
+		t.Fatalf("Ожидалась пустая база, получено: %v", db.Packages)
+	}
+
+	db.Put(Package{Name: "lib", Version: "1.0.0"})
+	db.Put(Package{Name: "app", Version: "1.0.0"})
+	db.Put(Package{Name: "lib", Version: "1.1.0"})
+	if err := Save(path, db); err != nil {
+		t.Fatalf("Ошибка Save: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Ошибка Load: %v", err)
+	}
+	if len(loaded.Packages) != 2 || loaded.Packages[0].Name != "app" {
+		t.Fatalf("Пакеты не совпадают: %v", loaded.Packages)
+	}
+	if p, ok := loaded.Find("lib"); !ok || p.Version != "1.1.0" {
+		t.Errorf("Find(lib) = %v, %v, ожидалась версия 1.1.0", p, ok)
+	}
+
+	if !loaded.Remove("app") || loaded.Remove("app") {
+		t.Error("Remove должен удалять пакет ровно один раз")
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Ожидалась ошибка для повреждённой базы")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "bin", "app"), []byte("abc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("bin/app", filepath.Join(root, "app")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Describe(root, []string{"app", "bin/app"})
+	if err != nil {
+		t.Fatalf("Ошибка Describe: %v", err)
+	}
+	if files[0].Link != "bin/app" || files[0].Checksum != "" {
+		t.Errorf("Ссылка описана неверно: %+v", files[0])
+	}
+	const abc = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if files[1].Checksum != abc {
+		t.Errorf("Контрольная сумма bin/app = %s, ожидалось %s", files[1].Checksum, abc)
+	}
+
+	if _, err := Describe(root, []string{"missing"}); err == nil {
+		t.Error("Ожидалась ошибка для отсутствующего файла")
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("app/bin/app", "app")
+	write("app/etc/app.conf", "conf")
+	write("share/common.txt", "common")
+	write("user.txt", "своё")
+	// etc была в корне до установки пакета
+	write("etc/app.ini", "ini")
+
+	describe := func(files ...string) []File {
+		t.Helper()
+		described, err := Describe(root, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return described
+	}
+	db := &DB{Version: formatVersion}
+	files := describe("app/bin/app", "app/etc/app.conf", "share/common.txt", "etc/app.ini")
+	for _, dir := range []string{"app", "app/bin", "app/etc"} {
+		files = append(files, File{Path: dir, Dir: true})
+	}
+	db.Put(Package{Name: "app", Files: files})
+	db.Put(Package{Name: "tool", Dependencies: []repository.Dependency{{Name: "app"}}, Files: describe("share/common.txt")})
+
+	if deps := db.Dependents("app"); len(deps) != 1 || deps[0].Name != "tool" {
+		t.Errorf("Dependents(app) = %v, ожидался tool", deps)
+	}
+
+	// пользователь изменил конфиг после установки
+	write("app/etc/app.conf", "изменено")
+
+	result, err := Uninstall(root, db, "app")
+	if err != nil {
+		t.Fatalf("Ошибка Uninstall: %v", err)
+	}
+	if want := []string{"app/bin/app", "etc/app.ini", "app/bin"}; !reflect.DeepEqual(result.Removed, want) {
+		t.Errorf("Removed = %v, ожидалось %v", result.Removed, want)
+	}
+	// app и app/etc не пусты только из-за оставленного конфига
+	if len(result.NotEmpty) != 0 {
+		t.Errorf("NotEmpty = %v, ожидался пустой список", result.NotEmpty)
+	}
+	if len(result.Modified) != 1 || result.Modified[0] != "app/etc/app.conf" {
+		t.Errorf("Modified = %v", result.Modified)
+	}
+	if len(result.Shared) != 1 || result.Shared[0] != "share/common.txt" {
+		t.Errorf("Shared = %v", result.Shared)
+	}
+
+	for _, path := range []string{"app/etc/app.conf", "share/common.txt", "user.txt", "etc"} {
+		if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+			t.Errorf("Путь %s должен остаться: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "app", "bin")); !os.IsNotExist(err) {
+		t.Error("Опустевшая директория app/bin не удалена")
+	}
+	if _, ok := db.Find("app"); ok {
+		t.Error("Запись о пакете не удалена")
+	}
+
+	if _, err := Uninstall(root, db, "app"); err == nil {
+		t.Error("Ожидалась ошибка для неустановленного пакета")
+	}
+}
+
+func TestUninstallDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"app/cache/tmp", "app/logs", "share/empty"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "app", "bin"), []byte("app"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// пользователь записал файл в директорию пакета
+	if err := os.WriteFile(filepath.Join(root, "app", "logs", "app.log"), []byte("log"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Describe(root, []string{"app/bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"app", "app/cache", "app/cache/tmp", "app/logs", "app/missing", "share/empty"} {
+		files = append(files, File{Path: dir, Dir: true})
+	}
+	db := &DB{Version: formatVersion}
+	db.Put(Package{Name: "app", Files: files})
+	db.Put(Package{Name: "tool", Files: []File{{Path: "share/empty", Dir: true}}})
+
+	result, err := Uninstall(root, db, "app")
+	if err != nil {
+		t.Fatalf("Ошибка Uninstall: %v", err)
+	}
+	if want := []string{"app/bin", "app/cache/tmp", "app/cache"}; !reflect.DeepEqual(result.Removed, want) {
+		t.Errorf("Removed = %v, ожидалось %v", result.Removed, want)
+	}
+	if want := []string{"app/logs"}; !reflect.DeepEqual(result.NotEmpty, want) {
+		t.Errorf("NotEmpty = %v, ожидалось %v", result.NotEmpty, want)
+	}
+	if want := []string{"share/empty"}; !reflect.DeepEqual(result.Shared, want) {
+		t.Errorf("Shared = %v, ожидалось %v", result.Shared, want)
+	}
+	if want := []string{"app/missing"}; !reflect.DeepEqual(result.Missing, want) {
+		t.Errorf("Missing = %v, ожидалось %v", result.Missing, want)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "app", "cache")); !os.IsNotExist(err) {
+		t.Error("Опустевшая директория app/cache не удалена")
+	}
+	for _, path := range []string{"app/logs/app.log", "share/empty"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(path))); err != nil {
+			t.Errorf("%s должен остаться: %v", path, err)
+		}
+	}
+}
+
+func TestCreatedDirs(t *testing.T) {
+	root := t.TempDir()
+	// etc создана пользователем, opt — pm для пакета lib
+	for _, dir := range []string{"etc", "opt/lib"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := &DB{Version: formatVersion}
+	db.Put(Package{Name: "lib", Files: []File{{Path: "opt", Dir: true}, {Path: "opt/lib", Dir: true}}})
+
+	got, err := db.CreatedDirs(root, []string{"etc/app.conf", "opt/app/bin/app", "app.txt"}, []string{"var/log/app", "etc"})
+	if err != nil {
+		t.Fatalf("Ошибка CreatedDirs: %v", err)
+	}
+	want := []string{"opt", "opt/app", "opt/app/bin", "var", "var/log", "var/log/app"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CreatedDirs = %v, ожидалось %v", got, want)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"old.txt", "both.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := Describe(root, []string{"both.txt", "old.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &DB{Version: formatVersion}
+	db.Put(Package{Name: "app", Version: "1.0", Files: files})
+
+	result, err := Replace(root, db, Package{Name: "app", Version: "2.0", Files: files[:1]}, os.Remove)
+	if err != nil {
+		t.Fatalf("Ошибка Replace: %v", err)
+	}
+	if len(result.Removed) != 1 || result.Removed[0] != "old.txt" {
+		t.Errorf("Removed = %v, ожидался только old.txt", result.Removed)
+	}
+	if _, err := os.Stat(filepath.Join(root, "both.txt")); err != nil {
+		t.Errorf("Файл новой версии удалён: %v", err)
+	}
+	if p, _ := db.Find("app"); p.Version != "2.0" {
+		t.Errorf("Версия в базе = %s, ожидалась 2.0", p.Version)
+	}
+}
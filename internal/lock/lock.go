@@ -0,0 +1,191 @@
+package lock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"pm/config"
+	"pm/internal/errors"
+	"pm/internal/repository"
+	"pm/pkg/version"
+)
+
+const (
+	FileName      = "pm.lock"
+	formatVersion = 1
+)
+
+type Requirement struct {
+	Name       string `json:"name"`
+	Ver        string `json:"ver,omitempty"`
+	Repository string `json:"repository,omitempty"`
+}
+
+type Package struct {
+	Name         string                  `json:"name"`
+	Version      string                  `json:"version"`
+	Format       string                  `json:"format"`
+	File         string                  `json:"file"`
+	Source       string                  `json:"source"`
+	Checksum     string                  `json:"sha256,omitempty"`
+	Dependencies []repository.Dependency `json:"dependencies,omitempty"`
+}
+
+type Lock struct {
+	Version      int           `json:"version"`
+	Requirements []Requirement `json:"requirements"`
+	Packages     []Package     `json:"packages"`
+}
+
+// PathFor возвращает путь к pm.lock рядом с файлом packages.json.
+func PathFor(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), FileName)
+}
+
+func New(pkgs *config.Packages, entries []repository.IndexEntry) *Lock {
+	l := &Lock{Version: formatVersion}
+	for _, p := range pkgs.Packages {
+		l.Requirements = append(l.Requirements, Requirement{Name: p.Name, Ver: p.Ver, Repository: p.Repository})
+	}
+	for _, e := range entries {
+		l.Packages = append(l.Packages, Package{
+			Name:         e.Name,
+			Version:      e.Version,
+			Format:       e.Format,
+			File:         e.File,
+			Source:       e.Source,
+			Checksum:     e.Checksum,
+			Dependencies: e.Dependencies,
+		})
+	}
+	sort.Slice(l.Packages, func(i, j int) bool {
+		return l.Packages[i].Name < l.Packages[j].Name
+	})
+	return l
+}
+
+func Load(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.ErrLockNotFound
+		}
+		return nil, err
+	}
+
+	var l Lock
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("повреждённый %s: %w", path, err)
+	}
+	if l.Version != formatVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия формата %s: %d", path, l.Version)
+	}
+
+	return &l, nil
+}
+
+func Save(path string, l *Lock) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(l); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (l *Lock) Find(name string) (Package, bool) {
+	for _, p := range l.Packages {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Package{}, false
+}
+
+// Check проверяет, что lock-файл соответствует packages.json: набор
+// требований не изменился, а зафиксированные версии удовлетворяют всем
+// условиям, включая условия транзитивных зависимостей.
+func (l *Lock) Check(pkgs *config.Packages) error {
+	var problems []string
+
+	current := make(map[string]config.Packet)
+	for _, p := range pkgs.Packages {
+		current[p.Name] = p
+	}
+	locked := make(map[string]Requirement)
+	for _, r := range l.Requirements {
+		locked[r.Name] = r
+	}
+
+	for name, p := range current {
+		r, ok := locked[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("пакет %s добавлен в конфигурацию", name))
+		case r.Ver != p.Ver:
+			problems = append(problems, fmt.Sprintf("условие для %s изменилось: %q -> %q", name, r.Ver, p.Ver))
+		case r.Repository != p.Repository:
+			problems = append(problems, fmt.Sprintf("репозиторий для %s изменился: %q -> %q", name, r.Repository, p.Repository))
+		}
+	}
+	for name := range locked {
+		if _, ok := current[name]; !ok {
+			problems = append(problems, fmt.Sprintf("пакет %s удалён из конфигурации", name))
+		}
+	}
+
+	check := func(name, constraint, from string) {
+		p, ok := l.Find(name)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: пакет %s не зафиксирован", from, name))
+			return
+		}
+		matches, err := version.Matches(p.Version, constraint)
+		if err != nil || !matches {
+			problems = append(problems, fmt.Sprintf("%s: %s@%s не удовлетворяет условию %q", from, name, p.Version, constraint))
+		}
+	}
+	for _, p := range pkgs.Packages {
+		check(p.Name, p.Ver, "конфигурация")
+	}
+	for _, p := range l.Packages {
+		for _, dep := range p.Dependencies {
+			check(dep.Name, dep.Ver, p.Name+"@"+p.Version)
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.NewLockOutdatedError(problems)
+	}
+	return nil
+}
+
+// Entries возвращает зафиксированные пакеты в виде записей индекса,
+// сгруппированные по репозиторию-источнику.
+func (l *Lock) Entries() map[string][]repository.IndexEntry {
+	bySource := make(map[string][]repository.IndexEntry)
+	for _, p := range l.Packages {
+		bySource[p.Source] = append(bySource[p.Source], repository.IndexEntry{
+			Name:         p.Name,
+			Version:      p.Version,
+			Format:       p.Format,
+			File:         p.File,
+			Checksum:     p.Checksum,
+			Dependencies: p.Dependencies,
+			Source:       p.Source,
+		})
+	}
+	return bySource
+}
@@ -0,0 +1,119 @@
+package lock
+
+import (
+	stderrors "errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pm/config"
+	"pm/internal/errors"
+	"pm/internal/repository"
+)
+
+func testEntries() []repository.IndexEntry {
+	return []repository.IndexEntry{
+		{
+			Name:         "app",
+			Version:      "1.2.0",
+			Format:       "zip",
+			File:         "app-1.2.0.zip",
+			Source:       "file:///srv/pm",
+			Checksum:     "aa",
+			Dependencies: []repository.Dependency{{Name: "lib", Ver: ">=1.0"}},
+		},
+		{Name: "lib", Version: "1.1.0", Format: "tar.gz", File: "lib-1.1.0.tar.gz", Source: "sftp://pkg.example.com/srv/pm"},
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	path := PathFor(filepath.Join(t.TempDir(), "packages.json"))
+	pkgs := &config.Packages{Packages: []config.Packet{{Name: "app", Ver: "^1.0"}}}
+
+	if _, err := Load(path); !stderrors.Is(err, errors.ErrLockNotFound) {
+		t.Fatalf("Ожидалась ErrLockNotFound, получено: %v", err)
+	}
+
+	if err := Save(path, New(pkgs, testEntries())); err != nil {
+		t.Fatalf("Ошибка Save: %v", err)
+	}
+	l, err := Load(path)
+	if err != nil {
+		t.Fatalf("Ошибка Load: %v", err)
+	}
+
+	if len(l.Requirements) != 1 || l.Requirements[0].Ver != "^1.0" {
+		t.Errorf("Требования не совпадают: %v", l.Requirements)
+	}
+	app, ok := l.Find("app")
+	if !ok || app.Checksum != "aa" || len(app.Dependencies) != 1 {
+		t.Errorf("Find(app) = %+v, %v", app, ok)
+	}
+
+	bySource := l.Entries()
+	if len(bySource["file:///srv/pm"]) != 1 || len(bySource["sftp://pkg.example.com/srv/pm"]) != 1 {
+		t.Errorf("Entries() сгруппированы неверно: %v", bySource)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	locked := New(&config.Packages{Packages: []config.Packet{{Name: "app", Ver: "^1.0"}}}, testEntries())
+
+	tests := []struct {
+		name string
+		lock func() *Lock
+		pkgs []config.Packet
+		want []string
+	}{
+		{name: "соответствует", pkgs: []config.Packet{{Name: "app", Ver: "^1.0"}}},
+		{name: "изменилось условие", pkgs: []config.Packet{{Name: "app", Ver: "^2.0"}}, want: []string{`условие для app изменилось`, `app@1.2.0 не удовлетворяет условию "^2.0"`}},
+		{name: "пакет добавлен", pkgs: []config.Packet{{Name: "app", Ver: "^1.0"}, {Name: "tool"}}, want: []string{"пакет tool добавлен", "пакет tool не зафиксирован"}},
+		{name: "пакет удалён", pkgs: []config.Packet{{Name: "lib"}}, want: []string{"пакет app удалён", "пакет lib добавлен"}},
+		{name: "изменился репозиторий", pkgs: []config.Packet{{Name: "app", Ver: "^1.0", Repository: "internal"}}, want: []string{"репозиторий для app изменился"}},
+		{
+			name: "зависимость не зафиксирована",
+			lock: func() *Lock {
+				return New(&config.Packages{Packages: []config.Packet{{Name: "app", Ver: "^1.0"}}}, testEntries()[:1])
+			},
+			pkgs: []config.Packet{{Name: "app", Ver: "^1.0"}},
+			want: []string{"app@1.2.0: пакет lib не зафиксирован"},
+		},
+		{
+			name: "устаревшая версия зависимости",
+			lock: func() *Lock {
+				entries := testEntries()
+				entries[1].Version = "0.9.0"
+				return New(&config.Packages{Packages: []config.Packet{{Name: "app", Ver: "^1.0"}}}, entries)
+			},
+			pkgs: []config.Packet{{Name: "app", Ver: "^1.0"}},
+			want: []string{`lib@0.9.0 не удовлетворяет условию ">=1.0"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := locked
+			if tt.lock != nil {
+				l = tt.lock()
+			}
+
+			err := l.Check(&config.Packages{Packages: tt.pkgs})
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Неожиданная ошибка: %v", err)
+				}
+				return
+			}
+
+			var outdated *errors.LockOutdatedError
+			if !stderrors.As(err, &outdated) {
+				t.Fatalf("Ожидалась ошибка LockOutdatedError, получено: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("В ошибке нет %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
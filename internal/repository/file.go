@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"pm/internal/errors"
+)
+
+type fileRepository struct {
+	url  string
+	root string
+}
+
+func openFile(u *url.URL) (Repository, error) {
+	root := u.Path
+	if u.Scheme == "" {
+		root = u.String()
+	} else if u.Host != "" && u.Host != "localhost" {
+		return nil, errors.NewRepositoryError(u.String(), "", fmt.Errorf("file:// поддерживает только локальные пути"))
+	}
+	if root == "" {
+		return nil, errors.NewRepositoryError(u.String(), "", fmt.Errorf("не указан путь к директории"))
+	}
+
+	return &fileRepository{url: u.String(), root: filepath.FromSlash(root)}, nil
+}
+
+func (r *fileRepository) URL() string {
+	return r.url
+}
+
+func (r *fileRepository) Upload(src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	defer f.Close()
+
+	return r.UploadReader(f, name)
+}
+
+func (r *fileRepository) UploadReader(rd io.Reader, name string) error {
+	dst := filepath.Join(r.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы читатели
+	// не увидели частично записанный архив
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, rd); err != nil {
+		tmp.Close()
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	return nil
+}
+
+func (r *fileRepository) CreateExclusive(rd io.Reader, name string) error {
+	dst := filepath.Join(r.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	if _, err := io.Copy(f, rd); err != nil {
+		f.Close()
+		os.Remove(dst)
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(dst)
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	return nil
+}
+
+func (r *fileRepository) Remove(name string) error {
+	if err := os.Remove(filepath.Join(r.root, filepath.FromSlash(name))); err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	return nil
+}
+
+func (r *fileRepository) Download(name, dst string) error {
+	src, err := os.Open(filepath.Join(r.root, filepath.FromSlash(name)))
+	if err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, src); err != nil {
+		return errors.NewRepositoryError(r.url, name, err)
+	}
+	return nil
+}
+
+func (r *fileRepository) List() ([]string, error) {
+	entries, err := os.ReadDir(r.root)
+	if err != nil {
+		return nil, errors.NewRepositoryError(r.url, "", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+func (r *fileRepository) Close() error {
+	return nil
+}
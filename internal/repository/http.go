@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"pm/internal/errors"
+)
+
+var hrefPattern = regexp.MustCompile(`(?i)href\s*=\s*["']([^"']+)["']`)
+
+type httpRepository struct {
+	base   *url.URL
+	client *http.Client
+}
+
+func openHTTP(u *url.URL) (Repository, error) {
+	base := *u
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	return &httpRepository{
+		base:   &base,
+		client: &http.Client{Timeout: 10 * time.Minute},
+	}, nil
+}
+
+func (r *httpRepository) URL() string {
+	return r.base.String()
+}
+
+func (r *httpRepository) Upload(src, name string) error {
+	return errors.NewRepositoryError(r.URL(), name, errors.ErrReadOnlyRepository)
+}
+
+func (r *httpRepository) UploadReader(rd io.Reader, name string) error {
+	return errors.NewRepositoryError(r.URL(), name, errors.ErrReadOnlyRepository)
+}
+
+func (r *httpRepository) CreateExclusive(rd io.Reader, name string) error {
+	return errors.NewRepositoryError(r.URL(), name, errors.ErrReadOnlyRepository)
+}
+
+func (r *httpRepository) Remove(name string) error {
+	return errors.NewRepositoryError(r.URL(), name, errors.ErrReadOnlyRepository)
+}
+
+func (r *httpRepository) get(name string) (io.ReadCloser, error) {
+	target := r.base.ResolveReference(&url.URL{Path: name})
+
+	resp, err := r.client.Get(target.String())
+	if err != nil {
+		return nil, errors.NewRepositoryError(r.URL(), name, err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errors.NewRepositoryError(r.URL(), name, os.ErrNotExist)
+	default:
+		resp.Body.Close()
+		return nil, errors.NewRepositoryError(r.URL(), name, fmt.Errorf("HTTP %s", resp.Status))
+	}
+}
+
+func (r *httpRepository) Download(name, dst string) error {
+	body, err := r.get(name)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.NewRepositoryError(r.URL(), name, err)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return errors.NewRepositoryError(r.URL(), name, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, body); err != nil {
+		return errors.NewRepositoryError(r.URL(), name, err)
+	}
+	return nil
+}
+
+// List разбирает автоиндекс веб-сервера (nginx autoindex, Apache mod_autoindex)
+// и возвращает имена файлов, лежащих непосредственно в базовой директории.
+func (r *httpRepository) List() ([]string, error) {
+	body, err := r.get("")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	page, err := io.ReadAll(body)
+	if err != nil {
+		return nil, errors.NewRepositoryError(r.URL(), "", err)
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range hrefPattern.FindAllStringSubmatch(string(page), -1) {
+		link, err := url.Parse(m[1])
+		if err != nil || link.RawQuery != "" || link.Fragment != "" {
+			continue
+		}
+
+		resolved := r.base.ResolveReference(link)
+		if resolved.Host != r.base.Host || path.Dir(resolved.Path) != path.Clean(r.base.Path) {
+			continue
+		}
+		if strings.HasSuffix(resolved.Path, "/") {
+			continue
+		}
+
+		name := path.Base(resolved.Path)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+func (r *httpRepository) Close() error {
+	r.client.CloseIdleConnections()
+	return nil
+}
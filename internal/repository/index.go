@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pm/internal/archive"
+	"pm/internal/errors"
+	"pm/internal/logger"
+	"pm/internal/utils"
+)
+
+const IndexFile = "index.json"
+
+// IndexLockFile существует, пока индекс изменяется: без него одновременные
+// публикации перечитывают и перезаписывают index.json, теряя записи друг друга.
+const IndexLockFile = IndexFile + ".lock"
+
+var (
+	indexLockTimeout = 2 * time.Minute
+	indexLockRetry   = 500 * time.Millisecond
+)
+
+type Dependency struct {
+	Name string `json:"name"`
+	Ver  string `json:"ver,omitempty"`
+}
+
+type IndexEntry struct {
+	Name         string       `json:"name"`
+	Version      string       `json:"version"`
+	Format       string       `json:"format"`
+	File         string       `json:"file"`
+	Size         int64        `json:"size"`
+	Checksum     string       `json:"sha256"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+	Source       string       `json:"-"`
+}
+
+type Index struct {
+	Updated  time.Time    `json:"updated"`
+	Packages []IndexEntry `json:"packages"`
+}
+
+func LoadIndex(repo Repository) (*Index, error) {
+	tmpDir, err := os.MkdirTemp("", "pm-index-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	local := filepath.Join(tmpDir, IndexFile)
+	if err := repo.Download(IndexFile, local); err != nil {
+		if stderrors.Is(err, os.ErrNotExist) {
+			return nil, errors.ErrIndexNotFound
+		}
+		return nil, err
+	}
+
+	data, err := os.ReadFile(local)
+	if err != nil {
+		return nil, err
+	}
+
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, errors.NewRepositoryError(repo.URL(), IndexFile, fmt.Errorf("повреждённый индекс: %w", err))
+	}
+
+	return &idx, nil
+}
+
+func SaveIndex(repo Repository, idx *Index) error {
+	idx.Updated = time.Now().UTC()
+	sort.Slice(idx.Packages, func(i, j int) bool {
+		if idx.Packages[i].Name != idx.Packages[j].Name {
+			return idx.Packages[i].Name < idx.Packages[j].Name
+		}
+		return idx.Packages[i].Version < idx.Packages[j].Version
+	})
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(idx); err != nil {
+		return err
+	}
+
+	return repo.UploadReader(&buf, IndexFile)
+}
+
+// LockIndex ждёт, пока индекс освободится, и блокирует его. Возвращает
+// функцию снятия блокировки.
+func LockIndex(log logger.LoggerInterface, repo Repository) (func() error, error) {
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s %d %s\n", host, os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+
+	deadline := time.Now().Add(indexLockTimeout)
+	waiting := false
+	for {
+		err := repo.CreateExclusive(strings.NewReader(owner), IndexLockFile)
+		if err == nil {
+			return func() error { return repo.Remove(IndexLockFile) }, nil
+		}
+		if !stderrors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.NewRepositoryError(repo.URL(), IndexLockFile,
+				fmt.Errorf("%w: удалите %s, если публикация была прервана", errors.ErrIndexLocked, IndexLockFile))
+		}
+		if !waiting {
+			log.Info("Индекс заблокирован другой публикацией, ожидание", "репозиторий", repo.URL(), "файл", IndexLockFile)
+			waiting = true
+		}
+		time.Sleep(indexLockRetry)
+	}
+}
+
+// Add заменяет запись с тем же именем файла или добавляет новую.
+func (idx *Index) Add(entry IndexEntry) {
+	for i, e := range idx.Packages {
+		if e.File == entry.File {
+			idx.Packages[i] = entry
+			return
+		}
+	}
+	idx.Packages = append(idx.Packages, entry)
+}
+
+// SetSource отмечает все записи индекса как полученные из репозитория url.
+func (idx *Index) SetSource(url string) {
+	for i := range idx.Packages {
+		idx.Packages[i].Source = url
+	}
+}
+
+// Merge объединяет индексы репозиториев, перечисленных в порядке
+// приоритета. Пакет целиком берётся из первого репозитория, в котором есть
+// пакет с таким именем: версии одноимённого пакета из менее приоритетных
+// репозиториев не смешиваются с ним. pins закрепляет пакет за репозиторием
+// с указанным адресом независимо от приоритета.
+func Merge(indexes []*Index, pins map[string]string) *Index {
+	owner := make(map[string]string)
+	for name, source := range pins {
+		owner[name] = source
+	}
+	for _, idx := range indexes {
+		for _, e := range idx.Packages {
+			if _, ok := owner[e.Name]; !ok {
+				owner[e.Name] = e.Source
+			}
+		}
+	}
+
+	merged := &Index{}
+	for _, idx := range indexes {
+		for _, e := range idx.Packages {
+			if owner[e.Name] == e.Source {
+				merged.Packages = append(merged.Packages, e)
+			}
+		}
+	}
+	return merged
+}
+
+func (idx *Index) Find(name string) []IndexEntry {
+	var entries []IndexEntry
+	for _, e := range idx.Packages {
+		if e.Name == name {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// IndexFromFiles строит индекс по именам файлов, когда index.json в
+// репозитории отсутствует. Размер и контрольная сумма при этом неизвестны.
+func IndexFromFiles(files []string) *Index {
+	idx := &Index{}
+	for _, f := range files {
+		name, version, format, ok := utils.ParseArchiveName(f)
+		if !ok {
+			continue
+		}
+		idx.Packages = append(idx.Packages, IndexEntry{
+			Name:    name,
+			Version: version,
+			Format:  format,
+			File:    f,
+		})
+	}
+	return idx
+}
+
+// Rebuild скачивает каждый архив репозитория, чтобы заново вычислить размер
+// и контрольную сумму. Зависимости читаются из описания пакета в архиве, а
+// для архивов без него переносятся из предыдущего индекса, если архив с тем
+// же содержимым в нём уже был. Архивы, зависимости которых узнать не
+// удалось, перечисляются в предупреждениях.
+func Rebuild(log logger.LoggerInterface, repo Repository, previous *Index) (*Index, error) {
+	files, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]IndexEntry)
+	if previous != nil {
+		for _, e := range previous.Packages {
+			known[e.File] = e
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "pm-reindex-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	idx := &Index{}
+	for _, entry := range IndexFromFiles(files).Packages {
+		local := filepath.Join(tmpDir, entry.File)
+		log.Debug("Скачивание архива для индексации", "файл", entry.File)
+		if err := repo.Download(entry.File, local); err != nil {
+			return nil, err
+		}
+
+		checksum, size, err := utils.FileSHA256(local)
+		if err != nil {
+			os.Remove(local)
+			return nil, err
+		}
+		entry.Checksum = checksum
+		entry.Size = size
+
+		manifest, err := readManifest(local, entry)
+		os.Remove(local)
+		if err != nil {
+			return nil, err
+		}
+
+		switch prev, ok := known[entry.File]; {
+		case manifest != nil:
+			// имя и версия из описания совпадают с записью, которую
+			// создаёт pm create, даже если имя файла разобрано иначе
+			entry.Name, entry.Version = manifest.Name, manifest.Ver
+			entry.Dependencies = manifest.Packets
+		case ok && prev.Checksum == checksum:
+			entry.Dependencies = prev.Dependencies
+		default:
+			log.Warn("Зависимости пакета неизвестны: в архиве нет описания пакета, а в прежнем индексе нет записи",
+				"имя", entry.Name, "версия", entry.Version, "файл", entry.File)
+		}
+
+		log.Debug("Архив добавлен в индекс", "имя", entry.Name, "версия", entry.Version, "файл", entry.File)
+		idx.Add(entry)
+	}
+
+	return idx, nil
+}
+
+// manifest — поля описания пакета, нужные индексу.
+type manifest struct {
+	Name    string       `json:"name"`
+	Ver     string       `json:"ver"`
+	Packets []Dependency `json:"packets"`
+}
+
+// readManifest читает описание пакета из скачанного архива. Возвращает nil
+// для архивов, созданных без описания.
+func readManifest(local string, entry IndexEntry) (*manifest, error) {
+	data, err := archive.ReadManifest(local, entry.Format)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения архива %s: %w", entry.File, err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("повреждённое описание пакета в архиве %s: %w", entry.File, err)
+	}
+	if m.Name == "" || m.Ver == "" {
+		return nil, fmt.Errorf("в описании пакета в архиве %s не указаны имя или версия", entry.File)
+	}
+	return &m, nil
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pm/internal/archive"
+	"pm/internal/errors"
+	"pm/internal/utils"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+// warnLogger запоминает предупреждения вместе с аргументами.
+type warnLogger struct {
+	nopLogger
+	mu    sync.Mutex
+	warns []string
+}
+
+func (l *warnLogger) Warn(msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warns = append(l.warns, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+
+func newFileRepository(t *testing.T) Repository {
+	t.Helper()
+	repo, err := openFile(&url.URL{Scheme: "file", Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestMerge(t *testing.T) {
+	internal := &Index{Packages: []IndexEntry{
+		{Name: "app", Version: "1.0"},
+		{Name: "app", Version: "1.1"},
+		{Name: "lib", Version: "1.0"},
+	}}
+	internal.SetSource("internal")
+	mirror := &Index{Packages: []IndexEntry{
+		{Name: "app", Version: "2.0"},
+		{Name: "lib", Version: "2.0"},
+		{Name: "zlib", Version: "1.0"},
+	}}
+	mirror.SetSource("mirror")
+
+	tests := []struct {
+		name string
+		pins map[string]string
+		want []string
+	}{
+		{
+			name: "пакет берётся из первого репозитория, где он есть",
+			want: []string{"app@1.0@internal", "app@1.1@internal", "lib@1.0@internal", "zlib@1.0@mirror"},
+		},
+		{
+			name: "закреплённый пакет",
+			pins: map[string]string{"app": "mirror"},
+			want: []string{"lib@1.0@internal", "app@2.0@mirror", "zlib@1.0@mirror"},
+		},
+		{
+			name: "закреплённый за репозиторием без пакета",
+			pins: map[string]string{"zlib": "internal"},
+			want: []string{"app@1.0@internal", "app@1.1@internal", "lib@1.0@internal"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := Merge([]*Index{internal, mirror}, tt.pins)
+
+			var got []string
+			for _, e := range merged.Packages {
+				got = append(got, e.Name+"@"+e.Version+"@"+e.Source)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Результат объединения не совпадает.\nОжидалось: %v\nПолучено: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLockIndex(t *testing.T) {
+	defer func(timeout, retry time.Duration) {
+		indexLockTimeout, indexLockRetry = timeout, retry
+	}(indexLockTimeout, indexLockRetry)
+	indexLockTimeout, indexLockRetry = 50*time.Millisecond, 10*time.Millisecond
+
+	repo := newFileRepository(t)
+	unlock, err := LockIndex(nopLogger{}, repo)
+	if err != nil {
+		t.Fatalf("Ошибка LockIndex: %v", err)
+	}
+
+	if _, err := LockIndex(nopLogger{}, repo); !stderrors.Is(err, errors.ErrIndexLocked) {
+		t.Fatalf("Ожидалась ошибка ErrIndexLocked, получено: %v", err)
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatalf("Ошибка снятия блокировки: %v", err)
+	}
+	root := repo.(*fileRepository).root
+	if _, err := os.Stat(filepath.Join(root, IndexLockFile)); !os.IsNotExist(err) {
+		t.Errorf("Файл блокировки не удалён: %v", err)
+	}
+
+	unlock, err = LockIndex(nopLogger{}, repo)
+	if err != nil {
+		t.Fatalf("Блокировка не захватывается повторно: %v", err)
+	}
+	unlock()
+}
+
+func TestLockIndexConcurrentPublish(t *testing.T) {
+	repo := newFileRepository(t)
+	defer func(retry time.Duration) { indexLockRetry = retry }(indexLockRetry)
+	indexLockRetry = time.Millisecond
+
+	const publishers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, publishers)
+	for i := 0; i < publishers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			unlock, err := LockIndex(nopLogger{}, repo)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer unlock()
+
+			idx, err := LoadIndex(repo)
+			if stderrors.Is(err, errors.ErrIndexNotFound) {
+				idx, err = &Index{}, nil
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			idx.Add(IndexEntry{Name: fmt.Sprintf("pkg%d", i), Version: "1.0", File: fmt.Sprintf("pkg%d-1.0.zip", i)})
+			errs <- SaveIndex(repo, idx)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Ошибка публикации: %v", err)
+		}
+	}
+
+	idx, err := LoadIndex(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Packages) != publishers {
+		t.Errorf("В индексе %d записей, ожидалось %d: записи потеряны", len(idx.Packages), publishers)
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	repo := newFileRepository(t)
+	root := repo.(*fileRepository).root
+
+	src := filepath.Join(t.TempDir(), "app")
+	if err := os.WriteFile(src, []byte("app"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	create := func(name string, manifest string) {
+		t.Helper()
+		var data []byte
+		if manifest != "" {
+			data = []byte(manifest)
+		}
+		err := archive.CreateZipWithManifest(nopLogger{}, []string{src}, filepath.Join(root, name), filepath.Dir(src), nil, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	create("app-2.0.zip", `{"name": "app", "ver": "2.0", "packets": [{"name": "lib", "ver": ">=1.0"}]}`)
+	create("app-1.0.zip", "")
+	create("tool-1.0.zip", "")
+
+	oldChecksum := func(file string) string {
+		sum, _, err := utils.FileSHA256(filepath.Join(root, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+	previous := &Index{Packages: []IndexEntry{
+		{Name: "app", Version: "1.0", File: "app-1.0.zip", Checksum: oldChecksum("app-1.0.zip"),
+			Dependencies: []Dependency{{Name: "lib", Ver: "1.0"}}},
+		{Name: "app", Version: "2.0", File: "app-2.0.zip", Checksum: "stale",
+			Dependencies: []Dependency{{Name: "old"}}},
+	}}
+
+	log := &warnLogger{}
+	idx, err := Rebuild(log, repo, previous)
+	if err != nil {
+		t.Fatalf("Ошибка Rebuild: %v", err)
+	}
+
+	want := map[string][]Dependency{
+		"app-1.0.zip":  {{Name: "lib", Ver: "1.0"}},
+		"app-2.0.zip":  {{Name: "lib", Ver: ">=1.0"}},
+		"tool-1.0.zip": nil,
+	}
+	if len(idx.Packages) != len(want) {
+		t.Fatalf("В индексе %d записей, ожидалось %d", len(idx.Packages), len(want))
+	}
+	for _, e := range idx.Packages {
+		if !reflect.DeepEqual(e.Dependencies, want[e.File]) {
+			t.Errorf("%s: зависимости %v, ожидалось %v", e.File, e.Dependencies, want[e.File])
+		}
+	}
+
+	if len(log.warns) != 1 || !strings.Contains(log.warns[0], "tool-1.0.zip") {
+		t.Errorf("Ожидалось одно предупреждение о tool-1.0.zip, получено: %v", log.warns)
+	}
+
+	create("bad-1.0.zip", `{"name": "bad"`)
+	if _, err := Rebuild(nopLogger{}, repo, nil); err == nil {
+		t.Error("Ожидалась ошибка для повреждённого описания пакета")
+	}
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"pm/internal/errors"
+	"pm/internal/logger"
+	"pm/internal/ssh"
+)
+
+type Repository interface {
+	URL() string
+	Upload(src, name string) error
+	UploadReader(r io.Reader, name string) error
+	// CreateExclusive записывает файл, только если его ещё нет. Для
+	// существующего файла возвращает ошибку, совместимую с os.ErrExist.
+	CreateExclusive(r io.Reader, name string) error
+	Remove(name string) error
+	Download(name, dst string) error
+	List() ([]string, error)
+	Close() error
+}
+
+type Options struct {
+	Log           logger.LoggerInterface
+	SSH           ssh.Config
+	SSHConfigFile *ssh.SSHConfigFile
+}
+
+func Open(rawURL string, opts Options) (Repository, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.NewRepositoryError(rawURL, "", err)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "sftp", "ssh":
+		return openSFTP(u, opts)
+	case "file", "":
+		return openFile(u)
+	case "http", "https":
+		return openHTTP(u)
+	default:
+		return nil, errors.NewRepositoryError(rawURL, "", fmt.Errorf("неподдерживаемая схема %q (допустимо: sftp, file, http, https)", u.Scheme))
+	}
+}
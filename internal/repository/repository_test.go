@@ -0,0 +1,195 @@
+package repository
+
+import (
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"pm/internal/errors"
+)
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		url     string
+		want    interface{}
+		wantURL string
+		wantErr bool
+	}{
+		{name: "file://", url: "file://" + dir, want: &fileRepository{}, wantURL: "file://" + dir},
+		{name: "путь без схемы", url: dir, want: &fileRepository{}, wantURL: dir},
+		{name: "file://localhost", url: "file://localhost" + dir, want: &fileRepository{}},
+		{name: "http", url: "http://repo.example.com/packages", want: &httpRepository{}, wantURL: "http://repo.example.com/packages/"},
+		{name: "https в верхнем регистре", url: "HTTPS://repo.example.com/packages/", want: &httpRepository{}},
+		{name: "неизвестная схема", url: "ftp://repo.example.com/packages", wantErr: true},
+		{name: "file:// на другом хосте", url: "file://remote/srv/packages", wantErr: true},
+		{name: "некорректный адрес", url: "http://[::1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := Open(tt.url, Options{Log: nopLogger{}})
+			if tt.wantErr {
+				if err == nil {
+					repo.Close()
+					t.Fatal("Ожидалась ошибка")
+				}
+				var repoErr *errors.RepositoryError
+				if !stderrors.As(err, &repoErr) {
+					t.Errorf("Ожидалась RepositoryError, получено %T: %v", err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			defer repo.Close()
+
+			if reflect.TypeOf(repo) != reflect.TypeOf(tt.want) {
+				t.Errorf("Выбран %T, ожидался %T", repo, tt.want)
+			}
+			if tt.wantURL != "" && repo.URL() != tt.wantURL {
+				t.Errorf("URL() = %q, ожидалось %q", repo.URL(), tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestFileRepository(t *testing.T) {
+	root := t.TempDir()
+	repo, err := Open("file://"+root, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(t.TempDir(), "app-1.0.zip")
+	if err := os.WriteFile(src, []byte("архив"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Upload(src, "app-1.0.zip"); err != nil {
+		t.Fatalf("Ошибка Upload: %v", err)
+	}
+	if err := repo.UploadReader(strings.NewReader("lib"), "lib-1.0.zip"); err != nil {
+		t.Fatalf("Ошибка UploadReader: %v", err)
+	}
+	if err := repo.UploadReader(strings.NewReader("вложенный"), "sub/nested.zip"); err != nil {
+		t.Fatalf("Ошибка UploadReader во вложенную директорию: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(root, "app-1.0.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Права загруженного файла = %v, ожидалось 0644", info.Mode().Perm())
+	}
+
+	names, err := repo.List()
+	if err != nil {
+		t.Fatalf("Ошибка List: %v", err)
+	}
+	sort.Strings(names)
+	if want := []string{"app-1.0.zip", "lib-1.0.zip"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List() = %v, ожидалось %v (без директорий и временных файлов)", names, want)
+	}
+
+	dst := filepath.Join(t.TempDir(), "cache", "app-1.0.zip")
+	if err := repo.Download("app-1.0.zip", dst); err != nil {
+		t.Fatalf("Ошибка Download: %v", err)
+	}
+	if data, err := os.ReadFile(dst); err != nil || string(data) != "архив" {
+		t.Errorf("Скачано %q, %v", data, err)
+	}
+	if err := repo.Download("sub/nested.zip", dst); err != nil {
+		t.Fatalf("Ошибка Download из вложенной директории: %v", err)
+	}
+
+	err = repo.Download("missing.zip", filepath.Join(t.TempDir(), "missing.zip"))
+	if !stderrors.Is(err, os.ErrNotExist) {
+		t.Errorf("Download несуществующего файла: %v, ожидалась os.ErrNotExist", err)
+	}
+
+	// Open не проверяет существование директории, ошибка возникает при чтении
+	missing, err := Open("file://"+filepath.Join(root, "missing"), Options{})
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	if _, err := missing.List(); err == nil {
+		t.Error("List несуществующей директории: ожидалась ошибка")
+	}
+}
+
+// autoindex — страница nginx autoindex с лишними ссылками, которые List
+// должен отбросить.
+const autoindex = `<html><head><title>Index of /repo/</title></head><body>
+<h1>Index of /repo/</h1><hr><pre><a href="../">../</a>
+<a href="sub/">sub/</a>
+<a href="app-1.0.zip">app-1.0.zip</a>
+<a href='lib-1.0.tar.gz'>lib-1.0.tar.gz</a>
+<a href="/repo/app-1.2.zip">app-1.2.zip</a>
+<a href="app-1.0.zip">app-1.0.zip</a>
+<a href="?C=N;O=D">Name</a>
+<a href="#top">наверх</a>
+<a href="/other/evil.zip">evil.zip</a>
+<a href="http://mirror.example.com/repo/remote.zip">remote.zip</a>
+<a href="sub/nested.zip">nested.zip</a>
+</pre><hr></body></html>`
+
+func TestHTTPRepository(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repo/":
+			w.Write([]byte(autoindex))
+		case "/repo/app-1.0.zip":
+			w.Write([]byte("архив"))
+		case "/repo/broken.zip":
+			http.Error(w, "сбой", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	repo, err := Open(server.URL+"/repo", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	names, err := repo.List()
+	if err != nil {
+		t.Fatalf("Ошибка List: %v", err)
+	}
+	if want := []string{"app-1.0.zip", "lib-1.0.tar.gz", "app-1.2.zip"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List() = %v, ожидалось %v", names, want)
+	}
+
+	dst := filepath.Join(t.TempDir(), "cache", "app-1.0.zip")
+	if err := repo.Download("app-1.0.zip", dst); err != nil {
+		t.Fatalf("Ошибка Download: %v", err)
+	}
+	if data, err := os.ReadFile(dst); err != nil || string(data) != "архив" {
+		t.Errorf("Скачано %q, %v", data, err)
+	}
+
+	err = repo.Download("missing.zip", filepath.Join(t.TempDir(), "missing.zip"))
+	if !stderrors.Is(err, os.ErrNotExist) {
+		t.Errorf("Ответ 404: %v, ожидалась os.ErrNotExist", err)
+	}
+	err = repo.Download("broken.zip", filepath.Join(t.TempDir(), "broken.zip"))
+	if err == nil || stderrors.Is(err, os.ErrNotExist) {
+		t.Errorf("Ответ 500: %v, ожидалась ошибка, отличная от os.ErrNotExist", err)
+	}
+
+	if err := repo.UploadReader(strings.NewReader("x"), "app-2.0.zip"); !stderrors.Is(err, errors.ErrReadOnlyRepository) {
+		t.Errorf("UploadReader: %v, ожидалась ErrReadOnlyRepository", err)
+	}
+}
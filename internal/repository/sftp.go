@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+
+	"pm/internal/errors"
+	"pm/internal/ssh"
+)
+
+type sftpRepository struct {
+	url    string
+	root   string
+	client ssh.ClientInterface
+}
+
+func openSFTP(u *url.URL, opts Options) (Repository, error) {
+	cfg := opts.SSH
+	if u.Hostname() != "" {
+		cfg.Host = u.Hostname()
+	}
+	if u.User != nil && u.User.Username() != "" {
+		cfg.User = u.User.Username()
+	}
+	if p := u.Port(); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, errors.NewRepositoryError(u.String(), "", fmt.Errorf("некорректный порт %q", p))
+		}
+		cfg.Port = port
+	}
+
+	if opts.SSHConfigFile != nil {
+		alias := cfg.Host
+		resolved, err := opts.SSHConfigFile.Resolve(cfg)
+		if err != nil {
+			return nil, errors.NewRepositoryError(u.String(), "", err)
+		}
+		cfg = resolved
+		if opts.Log != nil && alias != cfg.Host {
+			opts.Log.Debug("Псевдоним хоста разрешён через SSH конфигурацию", "псевдоним", alias, "хост", cfg.Host)
+		}
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 22
+	}
+
+	if !cfg.HasCredentials() {
+		return nil, errors.ErrInvalidSSHConfig
+	}
+
+	if opts.Log != nil {
+		for _, jump := range cfg.ProxyJump {
+			opts.Log.Debug("Подключение через промежуточный хост", "хост", jump.String())
+		}
+		opts.Log.Debug("Подключение к SSH серверу", "хост", cfg.Host, "пользователь", cfg.User, "порт", cfg.Port)
+	}
+
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	root := u.Path
+	if root == "" {
+		root = "/"
+	}
+
+	return &sftpRepository{url: u.String(), root: root, client: client}, nil
+}
+
+func (r *sftpRepository) URL() string {
+	return r.url
+}
+
+func (r *sftpRepository) remotePath(name string) string {
+	return path.Join(r.root, name)
+}
+
+func (r *sftpRepository) Upload(src, name string) error {
+	return r.client.Upload(src, r.remotePath(name))
+}
+
+func (r *sftpRepository) UploadReader(rd io.Reader, name string) error {
+	return r.client.UploadReader(rd, r.remotePath(name))
+}
+
+func (r *sftpRepository) CreateExclusive(rd io.Reader, name string) error {
+	return r.client.CreateExclusive(rd, r.remotePath(name))
+}
+
+func (r *sftpRepository) Remove(name string) error {
+	return r.client.Remove(r.remotePath(name))
+}
+
+func (r *sftpRepository) Download(name, dst string) error {
+	return r.client.Download(r.remotePath(name), dst)
+}
+
+func (r *sftpRepository) List() ([]string, error) {
+	files, err := r.client.ReadDir(r.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		names = append(names, f.Name())
+	}
+	return names, nil
+}
+
+func (r *sftpRepository) Close() error {
+	return r.client.Close()
+}
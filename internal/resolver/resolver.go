@@ -0,0 +1,284 @@
+package resolver
+
+import (
+	"fmt"
+
+	"pm/internal/errors"
+	"pm/internal/logger"
+	"pm/internal/repository"
+	"pm/pkg/version"
+)
+
+const maxSteps = 100000
+
+type Requirement struct {
+	Name       string
+	Constraint string
+	From       string
+}
+
+func (r Requirement) String() string {
+	from := r.From
+	if from == "" {
+		from = "конфигурация"
+	}
+	constraint := r.Constraint
+	if constraint == "" {
+		constraint = "любая версия"
+	}
+	return fmt.Sprintf("%s требует %s %s", from, r.Name, constraint)
+}
+
+type Resolver struct {
+	log      logger.LoggerInterface
+	index    *repository.Index
+	strategy version.Strategy
+	steps    int
+	conflict error
+}
+
+type state struct {
+	selected     map[string]repository.IndexEntry
+	requirements map[string][]Requirement
+	order        []string
+}
+
+func New(log logger.LoggerInterface, index *repository.Index, strategy version.Strategy) *Resolver {
+	return &Resolver{log: log, index: index, strategy: strategy}
+}
+
+// Resolve подбирает версии для корневых требований и всех их транзитивных
+// зависимостей перебором с возвратом. Результат упорядочен так, что
+// зависимости идут раньше зависящих от них пакетов.
+func (r *Resolver) Resolve(roots []Requirement) ([]repository.IndexEntry, error) {
+	st := &state{
+		selected:     map[string]repository.IndexEntry{},
+		requirements: map[string][]Requirement{},
+	}
+	for _, req := range roots {
+		if err := validateConstraint(req); err != nil {
+			return nil, err
+		}
+		st.addRequirement(req)
+	}
+
+	r.steps = 0
+	r.conflict = nil
+	solution, err := r.solve(st)
+	if err != nil {
+		return nil, err
+	}
+	if solution == nil {
+		if r.conflict != nil {
+			return nil, r.conflict
+		}
+		return nil, fmt.Errorf("не удалось разрешить зависимости")
+	}
+
+	return solution.ordered(roots), nil
+}
+
+func (r *Resolver) solve(st *state) (*state, error) {
+	r.steps++
+	if r.steps > maxSteps {
+		return nil, fmt.Errorf("превышен лимит шагов разрешения зависимостей (%d)", maxSteps)
+	}
+
+	name, ok := st.nextUnresolved()
+	if !ok {
+		return st, nil
+	}
+
+	candidates := r.candidates(name, st.requirements[name])
+	if len(candidates) == 0 {
+		r.conflict = r.conflictError(name, st.requirements[name])
+		r.log.Debug("Нет подходящих версий", "пакет", name)
+		return nil, nil
+	}
+
+	for _, candidate := range candidates {
+		id := candidate.Name + "@" + candidate.Version
+
+		if cycle := st.cycleThrough(candidate); cycle != nil {
+			r.conflict = errors.NewDependencyCycleError(cycle)
+			r.log.Debug("Кандидат образует цикл", "пакет", id)
+			continue
+		}
+
+		next := st.clone()
+		next.selected[name] = candidate
+
+		compatible := true
+		for _, dep := range candidate.Dependencies {
+			req := Requirement{Name: dep.Name, Constraint: dep.Ver, From: id}
+			if err := validateConstraint(req); err != nil {
+				return nil, err
+			}
+			next.addRequirement(req)
+
+			if selected, ok := next.selected[dep.Name]; ok && !matches(selected.Version, dep.Ver) {
+				r.conflict = r.conflictError(dep.Name, next.requirements[dep.Name])
+				compatible = false
+				break
+			}
+		}
+		if !compatible {
+			r.log.Debug("Кандидат конфликтует с выбранными пакетами", "пакет", id)
+			continue
+		}
+
+		r.log.Debug("Пробуем версию", "пакет", id)
+		solution, err := r.solve(next)
+		if err != nil {
+			return nil, err
+		}
+		if solution != nil {
+			return solution, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *Resolver) candidates(name string, reqs []Requirement) []repository.IndexEntry {
+	entries := r.index.Find(name)
+	versions := make([]string, len(entries))
+	for i, e := range entries {
+		versions[i] = e.Version
+	}
+
+	var result []repository.IndexEntry
+	for _, i := range version.Sort(versions, r.strategy) {
+		ok := true
+		for _, req := range reqs {
+			if !matches(entries[i].Version, req.Constraint) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			result = append(result, entries[i])
+		}
+	}
+	return result
+}
+
+func (r *Resolver) conflictError(name string, reqs []Requirement) error {
+	var requirements []string
+	for _, req := range reqs {
+		requirements = append(requirements, req.String())
+	}
+
+	entries := r.index.Find(name)
+	versions := make([]string, len(entries))
+	for i, e := range entries {
+		versions[i] = e.Version
+	}
+	var available []string
+	for _, i := range version.Sort(versions, version.Lowest) {
+		available = append(available, versions[i])
+	}
+
+	return errors.NewDependencyConflictError(name, requirements, available)
+}
+
+func validateConstraint(req Requirement) error {
+	if req.Constraint == "" {
+		return nil
+	}
+	if _, err := version.ParseConstraint(req.Constraint); err != nil {
+		return errors.NewVersionError("", req.Constraint, fmt.Errorf("%s: %w", req.String(), err))
+	}
+	return nil
+}
+
+func matches(v, constraint string) bool {
+	ok, err := version.Matches(v, constraint)
+	return err == nil && ok
+}
+
+func (st *state) addRequirement(req Requirement) {
+	if _, ok := st.requirements[req.Name]; !ok {
+		st.order = append(st.order, req.Name)
+	}
+	st.requirements[req.Name] = append(st.requirements[req.Name], req)
+}
+
+func (st *state) nextUnresolved() (string, bool) {
+	for _, name := range st.order {
+		if _, ok := st.selected[name]; !ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// cycleThrough возвращает цикл, который появится при выборе candidate:
+// путь от одной из его зависимостей обратно к нему по уже выбранным пакетам.
+func (st *state) cycleThrough(candidate repository.IndexEntry) []string {
+	for _, dep := range candidate.Dependencies {
+		if path := st.path(dep.Name, candidate.Name, map[string]bool{}); path != nil {
+			return append([]string{candidate.Name}, path...)
+		}
+	}
+	return nil
+}
+
+func (st *state) path(from, to string, visited map[string]bool) []string {
+	if from == to {
+		return []string{to}
+	}
+	if visited[from] {
+		return nil
+	}
+	visited[from] = true
+
+	entry, ok := st.selected[from]
+	if !ok {
+		return nil
+	}
+	for _, dep := range entry.Dependencies {
+		if rest := st.path(dep.Name, to, visited); rest != nil {
+			return append([]string{from}, rest...)
+		}
+	}
+	return nil
+}
+
+func (st *state) clone() *state {
+	next := &state{
+		selected:     make(map[string]repository.IndexEntry, len(st.selected)+1),
+		requirements: make(map[string][]Requirement, len(st.requirements)),
+		order:        append([]string(nil), st.order...),
+	}
+	for k, v := range st.selected {
+		next.selected[k] = v
+	}
+	for k, v := range st.requirements {
+		next.requirements[k] = append([]Requirement(nil), v...)
+	}
+	return next
+}
+
+func (st *state) ordered(roots []Requirement) []repository.IndexEntry {
+	var result []repository.IndexEntry
+	visited := map[string]bool{}
+
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		entry := st.selected[name]
+		for _, dep := range entry.Dependencies {
+			visit(dep.Name)
+		}
+		result = append(result, entry)
+	}
+
+	for _, root := range roots {
+		visit(root.Name)
+	}
+	return result
+}
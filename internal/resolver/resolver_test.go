@@ -0,0 +1,149 @@
+package resolver
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"pm/internal/errors"
+	"pm/internal/repository"
+	"pm/pkg/version"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+func entry(name, ver string, deps ...repository.Dependency) repository.IndexEntry {
+	return repository.IndexEntry{Name: name, Version: ver, Format: "zip", File: name + "-" + ver + ".zip", Dependencies: deps}
+}
+
+func dep(name, ver string) repository.Dependency {
+	return repository.Dependency{Name: name, Ver: ver}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		packages  []repository.IndexEntry
+		roots     []Requirement
+		strategy  version.Strategy
+		want      []string
+		wantCycle bool
+		wantConfl string
+	}{
+		{
+			name: "транзитивные зависимости, сначала зависимости",
+			packages: []repository.IndexEntry{
+				entry("app", "1.0", dep("utils", ">=1.5")),
+				entry("utils", "1.4"),
+				entry("utils", "1.6", dep("base", "")),
+				entry("base", "2.0"),
+			},
+			roots:    []Requirement{{Name: "app", Constraint: ">=1.0"}},
+			strategy: version.Highest,
+			want:     []string{"base@2.0", "utils@1.6", "app@1.0"},
+		},
+		{
+			name: "откат к более старой версии при конфликте",
+			packages: []repository.IndexEntry{
+				entry("app", "2.0", dep("utils", ">=2.0")),
+				entry("app", "1.0", dep("utils", "<2.0")),
+				entry("utils", "1.9"),
+				entry("utils", "2.1"),
+			},
+			roots: []Requirement{
+				{Name: "app"},
+				{Name: "utils", Constraint: "<2.0"},
+			},
+			strategy: version.Highest,
+			want:     []string{"utils@1.9", "app@1.0"},
+		},
+		{
+			name: "минимальные версии",
+			packages: []repository.IndexEntry{
+				entry("app", "1.0", dep("utils", ">=1.0")),
+				entry("app", "1.5", dep("utils", ">=1.0")),
+				entry("utils", "1.0"),
+				entry("utils", "1.2"),
+			},
+			roots:    []Requirement{{Name: "app", Constraint: ">=1.0"}},
+			strategy: version.Lowest,
+			want:     []string{"utils@1.0", "app@1.0"},
+		},
+		{
+			name: "неразрешимый конфликт",
+			packages: []repository.IndexEntry{
+				entry("app", "1.0", dep("utils", ">=2.0")),
+				entry("utils", "1.9"),
+			},
+			roots:     []Requirement{{Name: "app"}},
+			strategy:  version.Highest,
+			wantConfl: "utils",
+		},
+		{
+			name: "отсутствующий пакет",
+			packages: []repository.IndexEntry{
+				entry("app", "1.0"),
+			},
+			roots:     []Requirement{{Name: "missing"}},
+			strategy:  version.Highest,
+			wantConfl: "missing",
+		},
+		{
+			name: "циклическая зависимость",
+			packages: []repository.IndexEntry{
+				entry("a", "1.0", dep("b", "")),
+				entry("b", "1.0", dep("a", "")),
+			},
+			roots:     []Requirement{{Name: "a"}},
+			strategy:  version.Highest,
+			wantCycle: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := &repository.Index{Packages: tt.packages}
+			got, err := New(nopLogger{}, idx, tt.strategy).Resolve(tt.roots)
+
+			if tt.wantCycle {
+				var cycleErr *errors.DependencyCycleError
+				if !stderrors.As(err, &cycleErr) {
+					t.Fatalf("Ожидалась ошибка цикла, получено: %v", err)
+				}
+				return
+			}
+
+			if tt.wantConfl != "" {
+				var conflictErr *errors.DependencyConflictError
+				if !stderrors.As(err, &conflictErr) {
+					t.Fatalf("Ожидалась ошибка конфликта, получено: %v", err)
+				}
+				if conflictErr.Package != tt.wantConfl {
+					t.Errorf("Конфликт в пакете %q, ожидался %q", conflictErr.Package, tt.wantConfl)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Не ожидалась ошибка: %v", err)
+			}
+
+			var ids []string
+			for _, e := range got {
+				ids = append(ids, e.Name+"@"+e.Version)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("Результат не совпадает.\nОжидалось: %v\nПолучено: %v", tt.want, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("Результат не совпадает.\nОжидалось: %v\nПолучено: %v", tt.want, ids)
+				}
+			}
+		})
+	}
+}
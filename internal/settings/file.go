@@ -0,0 +1,185 @@
+package settings
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pm/internal/errors"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const ProjectFile = "pm.yaml"
+
+// SystemPath — общий для всех пользователей файл, PM_SYSTEM_CONFIG
+// переопределяет путь.
+func SystemPath() string {
+	if path := os.Getenv("PM_SYSTEM_CONFIG"); path != "" {
+		return path
+	}
+	return "/etc/pm/config.yaml"
+}
+
+// UserPath — файл пользователя, PM_CONFIG переопределяет путь.
+func UserPath() string {
+	if path := os.Getenv("PM_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pm", "config.yaml")
+}
+
+// ProjectPath — файл проекта в текущей директории.
+func ProjectPath() string {
+	return ProjectFile
+}
+
+type entry struct {
+	key    string
+	value  string
+	line   int
+	column int
+}
+
+// readFile читает файл настроек и раскладывает вложенные секции в плоские
+// ключи: ssh: {port: 22} превращается в ssh.port. Отсутствующий файл не
+// считается ошибкой.
+func readFile(path string) ([]entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, errors.NewConfigValidationError(path, []errors.ConfigProblem{{Message: err.Error()}})
+	}
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+
+	var entries []entry
+	var problems []errors.ConfigProblem
+	var walk func(n *yaml.Node, key string)
+	walk = func(n *yaml.Node, key string) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				child := n.Content[i].Value
+				if key != "" {
+					child = key + "." + child
+				}
+				walk(n.Content[i+1], child)
+			}
+		case yaml.SequenceNode:
+			var items []string
+			for _, c := range n.Content {
+				if c.Kind != yaml.ScalarNode {
+					problems = append(problems, errors.ConfigProblem{Line: c.Line, Column: c.Column, Field: key, Message: "ожидается список строк"})
+					return
+				}
+				items = append(items, c.Value)
+			}
+			entries = append(entries, entry{key, strings.Join(items, ","), n.Line, n.Column})
+		case yaml.ScalarNode:
+			if key == "" {
+				problems = append(problems, errors.ConfigProblem{Line: n.Line, Column: n.Column, Message: "ожидается набор параметров"})
+				return
+			}
+			value := n.Value
+			if n.Tag == "!!null" {
+				value = ""
+			}
+			entries = append(entries, entry{key, value, n.Line, n.Column})
+		default:
+			problems = append(problems, errors.ConfigProblem{Line: n.Line, Column: n.Column, Field: key, Message: "неподдерживаемое значение"})
+		}
+	}
+	walk(root.Content[0], "")
+
+	for _, e := range entries {
+		if err := Validate(e.key, e.value); err != nil {
+			problems = append(problems, errors.ConfigProblem{Line: e.line, Column: e.column, Field: e.key, Message: err.Error()})
+		}
+	}
+	if len(problems) > 0 {
+		return nil, errors.NewConfigValidationError(path, problems)
+	}
+
+	return entries, nil
+}
+
+// Set записывает значение параметра в файл, создавая его при
+// необходимости. Комментарии и порядок остальных параметров сохраняются.
+func Set(path, key, value string) error {
+	if err := Validate(key, value); err != nil {
+		return err
+	}
+
+	var root yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return fmt.Errorf("ошибка чтения %s: %w", path, err)
+		}
+	}
+	if len(root.Content) == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	node := root.Content[0]
+	segments := strings.Split(key, ".")
+	for i, seg := range segments {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: параметр %s не является секцией", path, strings.Join(segments[:i], "."))
+		}
+
+		var child *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == seg {
+				child = node.Content[j+1]
+				break
+			}
+		}
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: seg}, child)
+		}
+
+		if i == len(segments)-1 {
+			*child = yaml.Node{Kind: yaml.ScalarNode, Value: value}
+			// без тега такие значения прочитались бы как пустые
+			if value == "~" || strings.EqualFold(value, "null") {
+				child.Tag = "!!str"
+			}
+		}
+		node = child
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
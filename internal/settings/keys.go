@@ -0,0 +1,199 @@
+package settings
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pm/internal/ssh"
+
+	"github.com/alecthomas/units"
+)
+
+// Key описывает параметр настроек: имя в файле, переменную окружения и
+// значение по умолчанию.
+type Key struct {
+	Name    string
+	Env     string
+	Default string
+	check   func(string) error
+}
+
+var Keys = []Key{
+	{Name: "profile", Env: "PM_PROFILE", check: checkName},
+	{Name: "repository", Env: "PM_REPOSITORY"},
+	{Name: "remote_path", Env: "PM_REMOTE_PATH", Default: "/tmp/pm/"},
+	{Name: "ssh.user", Env: "PM_SSH_USER"},
+	{Name: "ssh.host", Env: "PM_SSH_HOST"},
+	{Name: "ssh.port", Env: "PM_SSH_PORT", check: checkPort},
+	{Name: "ssh.key", Env: "PM_SSH_KEY"},
+	{Name: "ssh.cert", Env: "PM_SSH_CERT"},
+	{Name: "ssh.auth", Env: "PM_SSH_AUTH", check: checkAuth},
+	{Name: "ssh.config", Env: "PM_SSH_CONFIG"},
+	{Name: "ssh.known_hosts", Env: "PM_SSH_KNOWN_HOSTS"},
+	{Name: "ssh.host_key_checking", Env: "PM_SSH_HOST_KEY_CHECKING", Default: "strict", check: checkHostKeyPolicy},
+	{Name: "ssh.hash_known_hosts", Env: "PM_SSH_HASH_KNOWN_HOSTS", Default: "no", check: checkBool},
+	{Name: "signing.key", Env: "PM_SIGNING_KEY"},
+	{Name: "signing.trusted_keys", Env: "PM_TRUSTED_KEYS"},
+	{Name: "signing.require", Env: "PM_REQUIRE_SIGNATURES", Default: "no", check: checkBool},
+	{Name: "cache.dir", Env: "PM_CACHE_DIR"},
+	{Name: "cache.max_size", Env: "PM_CACHE_MAX_SIZE", Default: "1GiB", check: checkSize},
+	{Name: "limits.max_size", Env: "PM_MAX_EXTRACT_SIZE", Default: "1GiB", check: checkSize},
+	{Name: "limits.max_file_size", Env: "PM_MAX_FILE_SIZE", Default: "512MiB", check: checkSize},
+	{Name: "limits.max_entries", Env: "PM_MAX_ENTRIES", Default: "100000", check: checkCount},
+	{Name: "limits.max_ratio", Env: "PM_MAX_RATIO", Default: "200", check: checkRatio},
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// lookupKey находит описание параметра. Кроме общих параметров допустимы
+// параметры именованных репозиториев (repositories.<имя>.url, .priority,
+// .ssh.*) и профилей (profiles.<имя>.<параметр>).
+func lookupKey(name string) (Key, error) {
+	for _, k := range Keys {
+		if k.Name == name {
+			return k, nil
+		}
+	}
+
+	parts := strings.SplitN(name, ".", 3)
+	if len(parts) == 3 {
+		switch parts[0] {
+		case "repositories":
+			if !namePattern.MatchString(parts[1]) {
+				return Key{}, fmt.Errorf("некорректное имя репозитория %q", parts[1])
+			}
+			switch parts[2] {
+			case "url":
+				return Key{Name: name, check: checkURL}, nil
+			case "priority":
+				return Key{Name: name, check: checkPriority}, nil
+			}
+			if strings.HasPrefix(parts[2], "ssh.") {
+				if k, err := lookupKey(parts[2]); err == nil {
+					return Key{Name: name, check: k.check}, nil
+				}
+			}
+		case "profiles":
+			if !namePattern.MatchString(parts[1]) {
+				return Key{}, fmt.Errorf("некорректное имя профиля %q", parts[1])
+			}
+			if parts[2] == "profile" || strings.HasPrefix(parts[2], "profiles.") {
+				return Key{}, fmt.Errorf("профиль не может задавать параметр %q", parts[2])
+			}
+			if k, err := lookupKey(parts[2]); err == nil {
+				return Key{Name: name, check: k.check}, nil
+			}
+		}
+	}
+
+	return Key{}, fmt.Errorf("неизвестный параметр %q", name)
+}
+
+// Validate проверяет имя параметра и его значение.
+func Validate(name, value string) error {
+	k, err := lookupKey(name)
+	if err != nil {
+		return err
+	}
+	if k.check == nil || value == "" {
+		return nil
+	}
+	return k.check(value)
+}
+
+func checkName(s string) error {
+	if !namePattern.MatchString(s) {
+		return fmt.Errorf("некорректное имя %q: допустимы буквы, цифры, '_' и '-'", s)
+	}
+	return nil
+}
+
+func checkPort(s string) error {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("некорректный порт %q: ожидается число от 1 до 65535", s)
+	}
+	return nil
+}
+
+func checkPriority(s string) error {
+	priority, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || priority < 1 {
+		return fmt.Errorf("некорректный приоритет %q: ожидается целое число от 1", s)
+	}
+	return nil
+}
+
+func checkSize(s string) error {
+	if _, err := parseSize(s); err != nil {
+		return fmt.Errorf("некорректный размер %q: ожидается число с единицей, например 512MiB", s)
+	}
+	return nil
+}
+
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" {
+		return 0, nil
+	}
+	size, err := units.ParseBase2Bytes(s)
+	return int64(size), err
+}
+
+func checkCount(s string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return fmt.Errorf("некорректное количество %q: ожидается целое число от 0", s)
+	}
+	return nil
+}
+
+func checkRatio(s string) error {
+	ratio, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || ratio < 0 {
+		return fmt.Errorf("некорректная степень сжатия %q: ожидается число от 0", s)
+	}
+	return nil
+}
+
+func checkAuth(s string) error {
+	_, err := ssh.ParseAuthMethods(s)
+	return err
+}
+
+func checkHostKeyPolicy(s string) error {
+	_, err := ssh.ParseHostKeyPolicy(s)
+	return err
+}
+
+func checkBool(s string) error {
+	_, err := parseBool(s)
+	return err
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "yes", "true", "on", "1":
+		return true, nil
+	case "", "no", "false", "off", "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("некорректное логическое значение %q: ожидается yes или no", s)
+	}
+}
+
+func checkURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("некорректный адрес репозитория %q: %v", s, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "sftp", "ssh", "file", "http", "https", "":
+		return nil
+	default:
+		return fmt.Errorf("неподдерживаемая схема %q (допустимо: sftp, file, http, https)", u.Scheme)
+	}
+}
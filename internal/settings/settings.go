@@ -0,0 +1,297 @@
+package settings
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Value — итоговое значение параметра и место, откуда оно взято.
+type Value struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Settings — настройки pm, собранные из слоёв в порядке возрастания
+// приоритета: значения по умолчанию, системный файл, файл пользователя,
+// файл проекта, активный профиль, переменные окружения, флаги -c.
+type Settings struct {
+	values  map[string]Value
+	profile string
+}
+
+type Options struct {
+	// Profile — профиль из флага --profile; пустая строка означает профиль
+	// из PM_PROFILE или параметра profile.
+	Profile string
+	// Overrides — параметры из флагов -c ключ=значение.
+	Overrides map[string]string
+}
+
+type layer struct {
+	source string
+	values map[string]string
+}
+
+func Load(opts Options) (*Settings, error) {
+	var files []layer
+	for _, f := range []struct{ path, source string }{
+		{SystemPath(), "системный файл"},
+		{UserPath(), "файл пользователя"},
+		{ProjectPath(), "файл проекта"},
+	} {
+		if f.path == "" {
+			continue
+		}
+		entries, err := readFile(f.path)
+		if err != nil {
+			return nil, err
+		}
+		l := layer{source: f.source + " " + f.path, values: map[string]string{}}
+		for _, e := range entries {
+			l.values[e.key] = e.value
+		}
+		files = append(files, l)
+	}
+
+	env := layer{values: map[string]string{}}
+	envSources := map[string]string{}
+	for _, k := range Keys {
+		value, ok := os.LookupEnv(k.Env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := Validate(k.Name, value); err != nil {
+			return nil, fmt.Errorf("переменная окружения %s: %w", k.Env, err)
+		}
+		env.values[k.Name] = value
+		envSources[k.Name] = "переменная окружения " + k.Env
+	}
+
+	flags := layer{source: "флаг -c", values: map[string]string{}}
+	for key, value := range opts.Overrides {
+		if err := Validate(key, value); err != nil {
+			return nil, fmt.Errorf("флаг -c %s: %w", key, err)
+		}
+		flags.values[key] = value
+	}
+
+	s := &Settings{values: map[string]Value{}}
+	for _, k := range Keys {
+		if k.Default != "" {
+			s.set(k.Name, k.Default, "по умолчанию")
+		}
+	}
+	for _, l := range files {
+		for key, value := range l.values {
+			s.set(key, value, l.source)
+		}
+	}
+
+	s.profile = opts.Profile
+	if s.profile == "" {
+		s.profile = flags.values["profile"]
+	}
+	if s.profile == "" {
+		s.profile = env.values["profile"]
+	}
+	if s.profile == "" {
+		s.profile = s.String("profile")
+	}
+	if s.profile != "" {
+		if err := checkName(s.profile); err != nil {
+			return nil, fmt.Errorf("профиль: %w", err)
+		}
+		if err := s.applyProfile(files); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range env.values {
+		s.set(key, value, envSources[key])
+	}
+	for key, value := range flags.values {
+		s.set(key, value, flags.source)
+	}
+	if opts.Profile != "" {
+		s.set("profile", opts.Profile, "флаг --profile")
+	}
+
+	return s, nil
+}
+
+// applyProfile переносит параметры profiles.<имя>.* поверх значений из
+// файлов. Профиль может быть описан в нескольких файлах: файл с большим
+// приоритетом переопределяет отдельные параметры.
+func (s *Settings) applyProfile(files []layer) error {
+	prefix := "profiles." + s.profile + "."
+	found := false
+	for _, l := range files {
+		for key, value := range l.values {
+			if name, ok := strings.CutPrefix(key, prefix); ok {
+				s.set(name, value, "профиль "+s.profile+", "+l.source)
+				found = true
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("профиль %q не описан ни в одном файле настроек", s.profile)
+	}
+	return nil
+}
+
+func (s *Settings) set(key, value, source string) {
+	s.values[key] = Value{Key: key, Value: value, Source: source}
+}
+
+func (s *Settings) Get(key string) (Value, bool) {
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *Settings) String(key string) string {
+	return s.values[key].Value
+}
+
+// Bool, Int и Size не возвращают ошибку: значения проверены при загрузке.
+func (s *Settings) Bool(key string) bool {
+	b, _ := parseBool(s.values[key].Value)
+	return b
+}
+
+func (s *Settings) Int(key string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s.values[key].Value))
+	return n
+}
+
+func (s *Settings) Float(key string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(s.values[key].Value), 64)
+	return f
+}
+
+// Size возвращает размер в байтах; значение вида 512MiB или 1GiB.
+func (s *Settings) Size(key string) int64 {
+	n, _ := parseSize(s.values[key].Value)
+	return n
+}
+
+func (s *Settings) IsSet(key string) bool {
+	v, ok := s.values[key]
+	return ok && v.Source != "по умолчанию"
+}
+
+// Profile возвращает имя активного профиля.
+func (s *Settings) Profile() string {
+	return s.profile
+}
+
+// Values возвращает все заданные параметры, отсортированные по имени.
+func (s *Settings) Values() []Value {
+	values := make([]Value, 0, len(s.values))
+	for _, v := range s.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return values
+}
+
+// Repository возвращает имя и адрес выбранного репозитория. Параметр
+// repository содержит имя из секции repositories или адрес; если он не
+// задан, адрес sftp:// собирается из ssh.host и remote_path. Пустой адрес
+// означает, что репозиторий не настроен.
+func (s *Settings) Repository() (string, string, error) {
+	repo := s.String("repository")
+	if repo == "" {
+		host := s.String("ssh.host")
+		if host == "" {
+			return "", "", nil
+		}
+		return "", (&url.URL{Scheme: "sftp", Host: host, Path: s.String("remote_path")}).String(), nil
+	}
+
+	if repoURL := s.String("repositories." + repo + ".url"); repoURL != "" {
+		return repo, repoURL, nil
+	}
+	if namePattern.MatchString(repo) {
+		return "", "", fmt.Errorf("репозиторий %q не описан: задайте repositories.%s.url", repo, repo)
+	}
+	return "", repo, nil
+}
+
+// DefaultPriority — приоритет репозитория, если он не задан явно. Чем
+// меньше значение, тем раньше репозиторий просматривается при установке.
+const DefaultPriority = 100
+
+type NamedRepository struct {
+	Name     string
+	URL      string
+	Priority int
+}
+
+// Repositories возвращает репозитории из секции repositories, у которых
+// задан адрес, в порядке приоритета.
+func (s *Settings) Repositories() []NamedRepository {
+	var repos []NamedRepository
+	for key, v := range s.values {
+		name, ok := strings.CutPrefix(key, "repositories.")
+		if !ok {
+			continue
+		}
+		if name, ok = strings.CutSuffix(name, ".url"); !ok || strings.Contains(name, ".") || v.Value == "" {
+			continue
+		}
+
+		priority := DefaultPriority
+		if s.IsSet("repositories." + name + ".priority") {
+			priority = s.Int("repositories." + name + ".priority")
+		}
+		repos = append(repos, NamedRepository{Name: name, URL: v.Value, Priority: priority})
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].Priority != repos[j].Priority {
+			return repos[i].Priority < repos[j].Priority
+		}
+		return repos[i].Name < repos[j].Name
+	})
+	return repos
+}
+
+// RepositoryName возвращает имя репозитория с адресом repoURL или пустую
+// строку, если такого репозитория нет в секции repositories.
+func (s *Settings) RepositoryName(repoURL string) string {
+	for key, v := range s.values {
+		name, ok := strings.CutPrefix(key, "repositories.")
+		if !ok || v.Value != repoURL {
+			continue
+		}
+		if name, ok = strings.CutSuffix(name, ".url"); ok && !strings.Contains(name, ".") {
+			return name
+		}
+	}
+	return ""
+}
+
+// ForRepository возвращает настройки для подключения к репозиторию name:
+// параметры repositories.<name>.ssh.* заменяют общие ssh.*.
+func (s *Settings) ForRepository(name string) *Settings {
+	if name == "" {
+		return s
+	}
+
+	view := &Settings{values: make(map[string]Value, len(s.values)), profile: s.profile}
+	for key, v := range s.values {
+		view.values[key] = v
+	}
+	prefix := "repositories." + name + "."
+	for key, v := range s.values {
+		if sub, ok := strings.CutPrefix(key, prefix); ok && strings.HasPrefix(sub, "ssh.") {
+			view.values[sub] = Value{Key: sub, Value: v.Value, Source: v.Source}
+		}
+	}
+	return view
+}
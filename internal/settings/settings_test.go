@@ -0,0 +1,292 @@
+package settings
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pm/internal/errors"
+)
+
+// setupFiles создаёт системный, пользовательский и проектный файлы настроек
+// и очищает переменные окружения, чтобы тест не зависел от машины.
+func setupFiles(t *testing.T, system, user, project string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, k := range Keys {
+		t.Setenv(k.Env, "")
+	}
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if content != "" {
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return path
+	}
+	t.Setenv("PM_SYSTEM_CONFIG", write("system.yaml", system))
+	t.Setenv("PM_CONFIG", write("user.yaml", user))
+	t.Chdir(dir)
+	write(ProjectFile, project)
+}
+
+func TestLoadLayers(t *testing.T) {
+	setupFiles(t,
+		"ssh:\n  user: system\n  port: 2200\nremote_path: /srv/pm\n",
+		"ssh:\n  user: user\n  key: ~/.ssh/pm\nprofiles:\n  ci:\n    ssh:\n      user: ci\n    signing:\n      require: yes\n",
+		"ssh:\n  host: project.example.com\nlimits:\n  max_size: 4GiB\n",
+	)
+
+	tests := []struct {
+		name   string
+		opts   Options
+		env    map[string]string
+		key    string
+		want   string
+		source string
+	}{
+		{name: "значение по умолчанию", key: "ssh.host_key_checking", want: "strict", source: "по умолчанию"},
+		{name: "системный файл", key: "ssh.port", want: "2200", source: "системный файл"},
+		{name: "файл пользователя перекрывает системный", key: "ssh.user", want: "user", source: "файл пользователя"},
+		{name: "файл проекта", key: "ssh.host", want: "project.example.com", source: "файл проекта"},
+		{name: "лимит распаковки из файла", key: "limits.max_size", want: "4GiB", source: "файл проекта"},
+		{name: "лимит распаковки по умолчанию", key: "limits.max_ratio", want: "200", source: "по умолчанию"},
+		{
+			name:   "лимит распаковки из окружения",
+			env:    map[string]string{"PM_MAX_ENTRIES": "500"},
+			key:    "limits.max_entries",
+			want:   "500",
+			source: "переменная окружения PM_MAX_ENTRIES",
+		},
+		{name: "профиль", opts: Options{Profile: "ci"}, key: "ssh.user", want: "ci", source: "профиль ci"},
+		{name: "профиль из окружения", env: map[string]string{"PM_PROFILE": "ci"}, key: "signing.require", want: "yes", source: "профиль ci"},
+		{
+			name:   "окружение перекрывает профиль",
+			opts:   Options{Profile: "ci"},
+			env:    map[string]string{"PM_SSH_USER": "env"},
+			key:    "ssh.user",
+			want:   "env",
+			source: "переменная окружения PM_SSH_USER",
+		},
+		{
+			name:   "флаг перекрывает окружение",
+			opts:   Options{Overrides: map[string]string{"ssh.user": "flag"}},
+			env:    map[string]string{"PM_SSH_USER": "env"},
+			key:    "ssh.user",
+			want:   "flag",
+			source: "флаг -c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			s, err := Load(tt.opts)
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+
+			v, ok := s.Get(tt.key)
+			if !ok {
+				t.Fatalf("Параметр %s не задан", tt.key)
+			}
+			if v.Value != tt.want {
+				t.Errorf("Значение %s не совпадает.\nОжидалось: %s\nПолучено: %s", tt.key, tt.want, v.Value)
+			}
+			if !strings.HasPrefix(v.Source, tt.source) {
+				t.Errorf("Источник %s не совпадает.\nОжидалось начало: %s\nПолучено: %s", tt.key, tt.source, v.Source)
+			}
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		opts    Options
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "порт вне диапазона в файле", user: "ssh:\n  port: 70000\n", wantErr: "2:9: ssh.port: некорректный порт"},
+		{name: "неизвестный параметр", user: "ssh:\n  hots: example.com\n", wantErr: `2:9: ssh.hots: неизвестный параметр`},
+		{name: "порт в окружении", env: map[string]string{"PM_SSH_PORT": "22x"}, wantErr: "PM_SSH_PORT: некорректный порт"},
+		{name: "логическое значение", opts: Options{Overrides: map[string]string{"signing.require": "maybe"}}, wantErr: "некорректное логическое значение"},
+		{name: "размер лимита", user: "limits:\n  max_file_size: много\n", wantErr: "limits.max_file_size: некорректный размер"},
+		{name: "отрицательное количество записей", env: map[string]string{"PM_MAX_ENTRIES": "-1"}, wantErr: "PM_MAX_ENTRIES: некорректное количество"},
+		{name: "степень сжатия", opts: Options{Overrides: map[string]string{"limits.max_ratio": "x"}}, wantErr: "некорректная степень сжатия"},
+		{name: "неизвестный профиль", opts: Options{Profile: "prod"}, wantErr: `профиль "prod" не описан`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupFiles(t, "", tt.user, "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := Load(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Ожидалась ошибка с %q, получено: %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("ошибки файла с позициями", func(t *testing.T) {
+		setupFiles(t, "", "ssh:\n  port: 0\n  auth: password\n", "")
+
+		_, err := Load(Options{})
+		var validationErr *errors.ConfigValidationError
+		if !stderrors.As(err, &validationErr) {
+			t.Fatalf("Ожидалась ошибка ConfigValidationError, получено: %v", err)
+		}
+		if len(validationErr.Problems) != 2 {
+			t.Errorf("Ожидалось 2 проблемы, получено: %v", validationErr.Problems)
+		}
+	})
+}
+
+func TestRepository(t *testing.T) {
+	setupFiles(t, "", `
+repository: prod
+repositories:
+  prod:
+    url: sftp://pkg.example.com/srv/pm
+    ssh:
+      user: deploy
+  local:
+    url: file:///var/pm
+ssh:
+  user: dev
+  host: dev.example.com
+`, "")
+
+	tests := []struct {
+		name     string
+		opts     Options
+		wantName string
+		wantURL  string
+		wantUser string
+		wantErr  bool
+	}{
+		{name: "именованный репозиторий", wantName: "prod", wantURL: "sftp://pkg.example.com/srv/pm", wantUser: "deploy"},
+		{name: "другой репозиторий", opts: Options{Overrides: map[string]string{"repository": "local"}}, wantName: "local", wantURL: "file:///var/pm", wantUser: "dev"},
+		{name: "адрес вместо имени", opts: Options{Overrides: map[string]string{"repository": "https://example.com/pm"}}, wantURL: "https://example.com/pm", wantUser: "dev"},
+		{name: "неизвестное имя", opts: Options{Overrides: map[string]string{"repository": "stage"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Load(tt.opts)
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+
+			name, repoURL, err := s.Repository()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ошибка = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if name != tt.wantName || repoURL != tt.wantURL {
+				t.Errorf("Репозиторий не совпадает.\nОжидалось: %s %s\nПолучено: %s %s", tt.wantName, tt.wantURL, name, repoURL)
+			}
+			if got := s.RepositoryName(repoURL); got != tt.wantName {
+				t.Errorf("RepositoryName(%s) = %q, ожидалось %q", repoURL, got, tt.wantName)
+			}
+			if user := s.ForRepository(name).String("ssh.user"); user != tt.wantUser {
+				t.Errorf("Пользователь SSH = %q, ожидалось %q", user, tt.wantUser)
+			}
+		})
+	}
+
+	t.Run("порядок по приоритету", func(t *testing.T) {
+		setupFiles(t, "", `
+repositories:
+  mirror:
+    url: file:///srv/mirror
+  internal:
+    url: sftp://pkg.example.com/srv/pm
+    priority: 10
+  backup:
+    url: file:///srv/backup
+`, "")
+		s, err := Load(Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, r := range s.Repositories() {
+			got = append(got, r.Name)
+		}
+		want := []string{"internal", "backup", "mirror"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Порядок репозиториев не совпадает.\nОжидалось: %v\nПолучено: %v", want, got)
+		}
+	})
+
+	t.Run("адрес из ssh.host", func(t *testing.T) {
+		setupFiles(t, "", "ssh:\n  host: example.com\n", "")
+		s, err := Load(Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, repoURL, _ := s.Repository(); repoURL != "sftp://example.com/tmp/pm/" {
+			t.Errorf("Адрес = %q, ожидалось sftp://example.com/tmp/pm/", repoURL)
+		}
+	})
+}
+
+func TestSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pm", "config.yaml")
+
+	steps := []struct{ key, value string }{
+		{"ssh.port", "2222"},
+		{"repositories.prod.url", "sftp://pkg.example.com/srv/pm"},
+		{"ssh.port", "22"},
+		{"profiles.ci.signing.require", "yes"},
+	}
+	for _, step := range steps {
+		if err := Set(path, step.key, step.value); err != nil {
+			t.Fatalf("Set(%s, %s): %v", step.key, step.value, err)
+		}
+	}
+
+	if err := Set(path, "ssh.port", "abc"); err == nil {
+		t.Error("Ожидалась ошибка для некорректного порта")
+	}
+	if err := Set(path, "ssh.prot", "22"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного параметра")
+	}
+
+	entries, err := readFile(path)
+	if err != nil {
+		t.Fatalf("Ошибка чтения записанного файла: %v", err)
+	}
+	got := map[string]string{}
+	for _, e := range entries {
+		got[e.key] = e.value
+	}
+	want := map[string]string{
+		"ssh.port":                    "22",
+		"repositories.prod.url":       "sftp://pkg.example.com/srv/pm",
+		"profiles.ci.signing.require": "yes",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Параметры не совпадают.\nОжидалось: %v\nПолучено: %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, ожидалось %q", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package signing
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pm/internal/errors"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	SignatureExtension = ".sig"
+	PublicKeyExtension = ".pub"
+
+	// signatureDomain отделяет подписи pm от любых других подписей тем же ключом.
+	signatureDomain = "pm-signature-v1\n"
+)
+
+// Signature — отсоединённая подпись архива. Подписывается не сам архив,
+// а пара «имя файла + SHA-256», поэтому подпись нельзя перенести на
+// другой файл с тем же содержимым.
+type Signature struct {
+	Key       string `json:"key"`
+	File      string `json:"file"`
+	Checksum  string `json:"sha256"`
+	Signature []byte `json:"signature"`
+}
+
+type KeyRing struct {
+	keys map[string]ed25519.PublicKey
+}
+
+func DefaultKeyPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pm", "signing_key")
+}
+
+func DefaultTrustedKeysPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pm", "trusted_keys")
+}
+
+// GenerateKey создаёт пару ключей ed25519 в формате OpenSSH: закрытый ключ
+// в path и открытый в path.pub. Существующие файлы не перезаписываются.
+func GenerateKey(path, comment string) (string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return "", err
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	pubLine := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+	if comment != "" {
+		pubLine += " " + comment
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := writeNew(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return "", err
+	}
+	if err := writeNew(path+PublicKeyExtension, []byte(pubLine+"\n"), 0644); err != nil {
+		return "", err
+	}
+
+	return Fingerprint(pub), nil
+}
+
+func writeNew(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func LoadPrivateKey(path, passphrase string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ssh.ParseRawPrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if stderrors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("ключ подписи %s защищён паролем: задайте PM_SIGNING_KEY_PASSPHRASE", path)
+		}
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(data, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ключа подписи %s: %w", path, err)
+	}
+
+	switch k := key.(type) {
+	case *ed25519.PrivateKey:
+		return *k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("ключ подписи %s должен быть ed25519, получен %T", path, key)
+	}
+}
+
+func Fingerprint(pub ed25519.PublicKey) string {
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	return ssh.FingerprintSHA256(sshPub)
+}
+
+func message(file, checksum string) []byte {
+	return []byte(signatureDomain + checksum + "  " + file + "\n")
+}
+
+func Sign(key ed25519.PrivateKey, file, checksum string) *Signature {
+	return &Signature{
+		Key:       Fingerprint(key.Public().(ed25519.PublicKey)),
+		File:      file,
+		Checksum:  checksum,
+		Signature: ed25519.Sign(key, message(file, checksum)),
+	}
+}
+
+func (s *Signature) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func ParseSignature(data []byte) (*Signature, error) {
+	var sig Signature
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return nil, fmt.Errorf("повреждённый файл подписи: %w", err)
+	}
+	if sig.Key == "" || len(sig.Signature) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("повреждённый файл подписи: нет ключа или подписи")
+	}
+	return &sig, nil
+}
+
+// LoadTrustedKeys читает доверенные открытые ключи в формате authorized_keys,
+// по одному на строку. Отсутствующий файл означает пустой список.
+func LoadTrustedKeys(path string) (*KeyRing, error) {
+	ring := &KeyRing{keys: map[string]ed25519.PublicKey{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ring, nil
+		}
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sshPub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		cryptoPub, ok := sshPub.(ssh.CryptoPublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: неподдерживаемый тип ключа %s", path, lineNum, sshPub.Type())
+		}
+		pub, ok := cryptoPub.CryptoPublicKey().(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: ключ должен быть ed25519, получен %s", path, lineNum, sshPub.Type())
+		}
+		ring.keys[Fingerprint(pub)] = pub
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+func (r *KeyRing) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.keys)
+}
+
+// Verify проверяет подпись файла file с контрольной суммой checksum,
+// вычисленной по скачанному архиву, а не взятой из самой подписи.
+func (r *KeyRing) Verify(sig *Signature, file, checksum string) error {
+	var pub ed25519.PublicKey
+	if r != nil {
+		pub = r.keys[sig.Key]
+	}
+	if pub == nil {
+		return errors.NewSignatureError(file, fmt.Errorf("ключ %s отсутствует в списке доверенных", sig.Key))
+	}
+	if !ed25519.Verify(pub, message(file, checksum), sig.Signature) {
+		return errors.NewSignatureError(file, fmt.Errorf("подпись ключом %s недействительна", sig.Key))
+	}
+	return nil
+}
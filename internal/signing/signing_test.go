@@ -0,0 +1,130 @@
+package signing
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pm/internal/errors"
+)
+
+// newKey создаёт пару ключей и возвращает закрытый ключ и путь к открытому.
+func newKey(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if _, err := GenerateKey(path, name); err != nil {
+		t.Fatalf("Ошибка GenerateKey: %v", err)
+	}
+	return path, path + PublicKeyExtension
+}
+
+func TestSignVerify(t *testing.T) {
+	dir := t.TempDir()
+	keyPath, pubPath := newKey(t, dir, "release")
+	_, otherPub := newKey(t, dir, "other")
+
+	if _, err := GenerateKey(keyPath, ""); err == nil {
+		t.Error("GenerateKey не должен перезаписывать существующий ключ")
+	}
+
+	key, err := LoadPrivateKey(keyPath, "")
+	if err != nil {
+		t.Fatalf("Ошибка LoadPrivateKey: %v", err)
+	}
+
+	const file, checksum = "app-1.0.zip", "4f6c1d0e5a"
+	data, err := Sign(key, file, checksum).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := ParseSignature(data)
+	if err != nil {
+		t.Fatalf("Ошибка ParseSignature: %v", err)
+	}
+
+	trusted := filepath.Join(dir, "trusted_keys")
+	pub, _ := os.ReadFile(pubPath)
+	if err := os.WriteFile(trusted, append([]byte("# ключи релизов\n\n"), pub...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ring, err := LoadTrustedKeys(trusted)
+	if err != nil || ring.Len() != 1 {
+		t.Fatalf("LoadTrustedKeys = %d ключей, %v", ring.Len(), err)
+	}
+
+	untrusted := filepath.Join(dir, "untrusted_keys")
+	other, _ := os.ReadFile(otherPub)
+	if err := os.WriteFile(untrusted, other, 0644); err != nil {
+		t.Fatal(err)
+	}
+	otherRing, err := LoadTrustedKeys(untrusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		ring     *KeyRing
+		file     string
+		checksum string
+		wantErr  bool
+	}{
+		{name: "доверенный ключ", ring: ring, file: file, checksum: checksum},
+		{name: "ключ не в списке доверенных", ring: otherRing, file: file, checksum: checksum, wantErr: true},
+		{name: "пустой список", ring: nil, file: file, checksum: checksum, wantErr: true},
+		{name: "изменённая контрольная сумма", ring: ring, file: file, checksum: "4f6c1d0e5b", wantErr: true},
+		{name: "подпись перенесена на другой файл", ring: ring, file: "app-2.0.zip", checksum: checksum, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ring.Verify(sig, tt.file, tt.checksum)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Неожиданная ошибка: %v", err)
+				}
+				return
+			}
+			var sigErr *errors.SignatureError
+			if !stderrors.As(err, &sigErr) {
+				t.Fatalf("Ожидалась ошибка SignatureError, получено: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseSignatureInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "не JSON", data: "signature"},
+		{name: "обрезанный JSON", data: `{"key": "SHA256:abc", "signature": "`},
+		{name: "нет ключа", data: `{"file": "app.zip", "signature": "AAAA"}`},
+		{name: "подпись неверной длины", data: `{"key": "SHA256:abc", "signature": "AAAA"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSignature([]byte(tt.data)); err == nil {
+				t.Error("Ожидалась ошибка разбора подписи")
+			}
+		})
+	}
+}
+
+func TestLoadTrustedKeysInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trusted_keys")
+	if err := os.WriteFile(path, []byte("ssh-ed25519 not-base64\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTrustedKeys(path); err == nil {
+		t.Error("Ожидалась ошибка для некорректного ключа")
+	}
+
+	ring, err := LoadTrustedKeys(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || ring.Len() != 0 {
+		t.Errorf("Отсутствующий файл должен давать пустой список: %d, %v", ring.Len(), err)
+	}
+}
@@ -0,0 +1,270 @@
+package ssh
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+)
+
+const (
+	AuthAgent = "agent"
+	AuthKey   = "key"
+)
+
+var DefaultAuthMethods = []string{AuthAgent, AuthKey}
+
+func ParseAuthMethods(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultAuthMethods, nil
+	}
+
+	var methods []string
+	for _, m := range strings.Split(s, ",") {
+		m = strings.ToLower(strings.TrimSpace(m))
+		switch m {
+		case AuthAgent, AuthKey:
+			methods = append(methods, m)
+		case "":
+		default:
+			return nil, fmt.Errorf("неизвестный метод аутентификации: %q (допустимо: agent, key)", m)
+		}
+	}
+	return methods, nil
+}
+
+type authenticator struct {
+	signers   []ssh.Signer
+	agentConn net.Conn
+}
+
+// newAuthenticator собирает подписчиков из всех методов в заданном порядке.
+// Все они передаются одним PublicKeysCallback: x/crypto пробует каждый метод
+// "publickey" только один раз, поэтому отдельные AuthMethod не сработали бы.
+func newAuthenticator(cfg Config) (*authenticator, error) {
+	methods := cfg.AuthMethods
+	if len(methods) == 0 {
+		methods = DefaultAuthMethods
+	}
+
+	a := &authenticator{}
+	var errs []string
+
+	for _, method := range methods {
+		switch method {
+		case AuthAgent:
+			if cfg.AgentSocket == "" {
+				continue
+			}
+			signers, err := a.agentSigners(cfg.AgentSocket)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("ssh-agent: %v", err))
+				continue
+			}
+			a.signers = append(a.signers, signers...)
+		case AuthKey:
+			if cfg.KeyPath == "" {
+				continue
+			}
+			signers, err := keySigners(cfg)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("ключ %s: %v", cfg.KeyPath, err))
+				continue
+			}
+			a.signers = append(a.signers, signers...)
+		}
+	}
+
+	if len(a.signers) == 0 {
+		a.Close()
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("нет доступных методов аутентификации: %s", strings.Join(errs, "; "))
+		}
+		return nil, fmt.Errorf("нет доступных методов аутентификации: не задан ни ключ, ни ssh-agent")
+	}
+
+	return a, nil
+}
+
+func (a *authenticator) Methods() []ssh.AuthMethod {
+	return []ssh.AuthMethod{ssh.PublicKeys(a.signers...)}
+}
+
+func (a *authenticator) Close() {
+	if a.agentConn != nil {
+		a.agentConn.Close()
+		a.agentConn = nil
+	}
+}
+
+func (a *authenticator) agentSigners(socket string) ([]ssh.Signer, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	a.agentConn = conn
+	return signers, nil
+}
+
+func keySigners(cfg Config) ([]ssh.Signer, error) {
+	keyData, err := os.ReadFile(cfg.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(keyData)
+	var missing *ssh.PassphraseMissingError
+	if stderrors.As(err, &missing) {
+		signer, err = encryptedKeySigner(cfg, keyData, missing.PublicKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	certPath := cfg.CertPath
+	if certPath == "" {
+		certPath = cfg.KeyPath + "-cert.pub"
+		if _, err := os.Stat(certPath); err != nil {
+			return []ssh.Signer{signer}, nil
+		}
+	}
+
+	certSigner, err := certificateSigner(certPath, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Если сервер не доверяет центру сертификации, остаётся обычный ключ
+	return []ssh.Signer{certSigner, signer}, nil
+}
+
+func certificateSigner(certPath string, signer ssh.Signer) (ssh.Signer, error) {
+	certData, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certData)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения сертификата %s: %w", certPath, err)
+	}
+
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s не является SSH-сертификатом", certPath)
+	}
+
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// encryptedKeySigner возвращает подписчика для ключа, защищённого паролем.
+// Если пароль не задан, но открытый ключ известен (из самого ключа OpenSSH
+// или из файла .pub), пароль спрашивается только при первой подписи, то есть
+// когда сервер принял этот ключ, а ключи ssh-agent перед ним не подошли.
+func encryptedKeySigner(cfg Config, keyData []byte, pub ssh.PublicKey) (ssh.Signer, error) {
+	if cfg.KeyPassphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(cfg.KeyPassphrase))
+	}
+
+	if pub == nil {
+		if pubData, err := os.ReadFile(cfg.KeyPath + ".pub"); err == nil {
+			pub, _, _, _, _ = ssh.ParseAuthorizedKey(pubData)
+		}
+	}
+	if pub == nil {
+		// без открытого ключа нечего предложить серверу до расшифровки
+		return decryptKey(cfg.KeyPath, keyData)
+	}
+	return &lazySigner{path: cfg.KeyPath, data: keyData, pub: pub}, nil
+}
+
+// decryptedKeys хранит расшифрованные ключи до конца работы процесса, чтобы
+// пароль не спрашивался заново для каждого репозитория.
+var decryptedKeys = struct {
+	sync.Mutex
+	signers map[string]ssh.Signer
+}{signers: make(map[string]ssh.Signer)}
+
+// readPassphrase запрашивает пароль ключа; подменяется в тестах.
+var readPassphrase = promptPassphrase
+
+func decryptKey(path string, keyData []byte) (ssh.Signer, error) {
+	decryptedKeys.Lock()
+	defer decryptedKeys.Unlock()
+
+	if signer, ok := decryptedKeys.signers[path]; ok {
+		return signer, nil
+	}
+
+	passphrase, err := readPassphrase(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	decryptedKeys.signers[path] = signer
+	return signer, nil
+}
+
+// lazySigner — ключ, защищённый паролем, который расшифровывается при
+// первой подписи.
+type lazySigner struct {
+	path string
+	data []byte
+	pub  ssh.PublicKey
+}
+
+func (s *lazySigner) PublicKey() ssh.PublicKey {
+	return s.pub
+}
+
+func (s *lazySigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	return s.SignWithAlgorithm(rand, data, "")
+}
+
+func (s *lazySigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	signer, err := decryptKey(s.path, s.data)
+	if err != nil {
+		return nil, err
+	}
+	if as, ok := signer.(ssh.AlgorithmSigner); ok {
+		return as.SignWithAlgorithm(rand, data, algorithm)
+	}
+	if algorithm != "" && algorithm != signer.PublicKey().Type() {
+		return nil, fmt.Errorf("ключ %s не поддерживает алгоритм %s", s.path, algorithm)
+	}
+	return signer.Sign(rand, data)
+}
+
+func promptPassphrase(keyPath string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("ключ защищён паролем: задайте PM_SSH_KEY_PASSPHRASE")
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "Введите пароль для ключа %s: ", keyPath)
+	passphrase, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", err
+	}
+
+	return string(passphrase), nil
+}
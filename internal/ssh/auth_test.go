@@ -0,0 +1,358 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// writeKey сохраняет новый ed25519-ключ в формате OpenSSH и возвращает его.
+func writeKey(t *testing.T, path, passphrase string) ed25519.PrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+// writeCert выпускает пользовательский сертификат для ключа и сохраняет его.
+func writeCert(t *testing.T, path string, key ed25519.PrivateKey) *ssh.Certificate {
+	t.Helper()
+	_, caKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := &ssh.Certificate{
+		Key:             pub,
+		CertType:        ssh.UserCert,
+		KeyId:           "deploy",
+		ValidPrincipals: []string{"deploy"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, ssh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// startAgent запускает ssh-agent с одним ключом на unix-сокете.
+func startAgent(t *testing.T, dir string) ssh.PublicKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	pub, err := ssh.NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func publicKey(t *testing.T, key ed25519.PrivateKey) ssh.PublicKey {
+	t.Helper()
+	pub, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func TestAuthenticatorOrder(t *testing.T) {
+	dir := t.TempDir()
+	agentKey := startAgent(t, dir)
+
+	keyPath := filepath.Join(dir, "id_ed25519")
+	fileKey := publicKey(t, writeKey(t, keyPath, ""))
+
+	certKeyPath := filepath.Join(dir, "id_cert")
+	certKey := writeKey(t, certKeyPath, "")
+	cert := writeCert(t, certKeyPath+"-cert.pub", certKey)
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    []ssh.PublicKey
+		wantErr bool
+	}{
+		{
+			name: "по умолчанию: агент, затем ключ",
+			cfg:  Config{AgentSocket: filepath.Join(dir, "agent.sock"), KeyPath: keyPath},
+			want: []ssh.PublicKey{agentKey, fileKey},
+		},
+		{
+			name: "ключ раньше агента",
+			cfg:  Config{AgentSocket: filepath.Join(dir, "agent.sock"), KeyPath: keyPath, AuthMethods: []string{AuthKey, AuthAgent}},
+			want: []ssh.PublicKey{fileKey, agentKey},
+		},
+		{
+			name: "сертификат перед своим ключом",
+			cfg:  Config{AgentSocket: filepath.Join(dir, "agent.sock"), KeyPath: certKeyPath},
+			want: []ssh.PublicKey{agentKey, cert, publicKey(t, certKey)},
+		},
+		{
+			name: "только ключ",
+			cfg:  Config{AgentSocket: filepath.Join(dir, "agent.sock"), KeyPath: keyPath, AuthMethods: []string{AuthKey}},
+			want: []ssh.PublicKey{fileKey},
+		},
+		{
+			name: "недоступный агент не мешает ключу",
+			cfg:  Config{AgentSocket: filepath.Join(dir, "missing.sock"), KeyPath: keyPath},
+			want: []ssh.PublicKey{fileKey},
+		},
+		{
+			name:    "нет ни ключа, ни агента",
+			cfg:     Config{},
+			wantErr: true,
+		},
+		{
+			name:    "отсутствующий файл ключа",
+			cfg:     Config{KeyPath: filepath.Join(dir, "missing")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newAuthenticator(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					a.Close()
+					t.Fatal("Ожидалась ошибка")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			defer a.Close()
+
+			if len(a.signers) != len(tt.want) {
+				t.Fatalf("Получено %d ключей, ожидалось %d", len(a.signers), len(tt.want))
+			}
+			for i, signer := range a.signers {
+				if !bytes.Equal(signer.PublicKey().Marshal(), tt.want[i].Marshal()) {
+					t.Errorf("Ключ %d: получен %s, ожидался %s", i, signer.PublicKey().Type(), tt.want[i].Type())
+				}
+			}
+			if len(a.Methods()) != 1 {
+				t.Errorf("Все ключи должны передаваться одним методом, получено %d", len(a.Methods()))
+			}
+		})
+	}
+}
+
+func TestKeySignersPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "id_ed25519")
+	key := publicKey(t, writeKey(t, keyPath, "секрет"))
+
+	signers, err := keySigners(Config{KeyPath: keyPath, KeyPassphrase: "секрет"})
+	if err != nil {
+		t.Fatalf("Ошибка загрузки ключа с паролем: %v", err)
+	}
+	if len(signers) != 1 || !bytes.Equal(signers[0].PublicKey().Marshal(), key.Marshal()) {
+		t.Fatalf("Загружен не тот ключ")
+	}
+
+	if _, err := keySigners(Config{KeyPath: keyPath, KeyPassphrase: "другой"}); err == nil {
+		t.Error("Ожидалась ошибка для неверного пароля")
+	}
+}
+
+func TestKeySignersCertificate(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "id_ed25519")
+	key := writeKey(t, keyPath, "")
+	certPath := filepath.Join(dir, "deploy-cert.pub")
+	cert := writeCert(t, certPath, key)
+
+	signers, err := keySigners(Config{KeyPath: keyPath, CertPath: certPath})
+	if err != nil {
+		t.Fatalf("Ошибка загрузки сертификата: %v", err)
+	}
+	if len(signers) != 2 {
+		t.Fatalf("Получено %d ключей, ожидалось 2", len(signers))
+	}
+	got, ok := signers[0].PublicKey().(*ssh.Certificate)
+	if !ok {
+		t.Fatalf("Первым должен идти сертификат, получено %s", signers[0].PublicKey().Type())
+	}
+	if got.KeyId != cert.KeyId {
+		t.Errorf("KeyId = %q, ожидалось %q", got.KeyId, cert.KeyId)
+	}
+
+	// Открытый ключ вместо сертификата
+	pubPath := filepath.Join(dir, "id_ed25519.pub")
+	if err := os.WriteFile(pubPath, ssh.MarshalAuthorizedKey(publicKey(t, key)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keySigners(Config{KeyPath: keyPath, CertPath: pubPath}); err == nil {
+		t.Error("Ожидалась ошибка для файла, не являющегося сертификатом")
+	}
+
+	// Сертификат для другого ключа
+	otherPath := filepath.Join(dir, "other")
+	writeKey(t, otherPath, "")
+	if _, err := keySigners(Config{KeyPath: otherPath, CertPath: certPath}); err == nil {
+		t.Error("Ожидалась ошибка для сертификата чужого ключа")
+	}
+}
+
+// dialAuth подключается к тестовому SSH-серверу, который принимает только
+// ключ allowed, и возвращает ошибку аутентификации.
+func dialAuth(t *testing.T, a *authenticator, allowed ssh.PublicKey) error {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), allowed.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("ключ не разрешён")
+		},
+	}
+	server.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		serverConn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer serverConn.Close()
+		if conn, _, _, err := ssh.NewServerConn(serverConn, server); err == nil {
+			conn.Close()
+		}
+	}()
+
+	clientConn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+	conn, _, _, err := ssh.NewClientConn(clientConn, l.Addr().String(), &ssh.ClientConfig{
+		User:            "deploy",
+		Auth:            a.Methods(),
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err == nil {
+		conn.Close()
+	}
+	return err
+}
+
+func TestEncryptedKeyPromptedLazily(t *testing.T) {
+	dir := t.TempDir()
+	agentKey := startAgent(t, dir)
+	keyPath := filepath.Join(dir, "id_ed25519")
+	fileKey := publicKey(t, writeKey(t, keyPath, "секрет"))
+
+	prompts := 0
+	original := readPassphrase
+	readPassphrase = func(string) (string, error) {
+		prompts++
+		return "секрет", nil
+	}
+	t.Cleanup(func() { readPassphrase = original })
+
+	cfg := Config{AgentSocket: filepath.Join(dir, "agent.sock"), KeyPath: keyPath}
+	connect := func(allowed ssh.PublicKey) {
+		t.Helper()
+		a, err := newAuthenticator(cfg)
+		if err != nil {
+			t.Fatalf("Ошибка newAuthenticator: %v", err)
+		}
+		defer a.Close()
+		if err := dialAuth(t, a, allowed); err != nil {
+			t.Fatalf("Ошибка аутентификации: %v", err)
+		}
+	}
+
+	connect(agentKey)
+	if prompts != 0 {
+		t.Errorf("Пароль запрошен %d раз, хотя подошёл ключ агента", prompts)
+	}
+
+	connect(fileKey)
+	if prompts != 1 {
+		t.Errorf("Пароль запрошен %d раз, ожидался 1", prompts)
+	}
+
+	// второе подключение (другой репозиторий) использует расшифрованный ключ
+	connect(fileKey)
+	if prompts != 1 {
+		t.Errorf("Пароль запрошен повторно: всего %d раз", prompts)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"pm/internal/errors"
 
@@ -14,73 +15,119 @@ import (
 
 type SSHClient struct {
 	sshClient *ssh.Client
+	jumps     []*ssh.Client
 	sftp      *sftp.Client
 }
 
-func NewClient(user, host, keyPath string, port int) (*SSHClient, error) {
-	keyData, err := os.ReadFile(keyPath)
+type Config struct {
+	User           string
+	Host           string
+	Port           int
+	KeyPath        string
+	KeyPassphrase  string
+	CertPath       string
+	AgentSocket    string
+	AuthMethods    []string
+	KnownHostsPath string
+	HostKeyPolicy  HostKeyPolicy
+	HashKnownHosts bool
+	ProxyJump      []JumpHost
+}
+
+func (c Config) HasCredentials() bool {
+	return c.User != "" && c.Host != "" && (c.KeyPath != "" || c.AgentSocket != "")
+}
+
+func NewClient(cfg Config) (*SSHClient, error) {
+	auth, err := newAuthenticator(cfg)
 	if err != nil {
-		return nil, errors.NewSSHConnectionError(host, err)
+		return nil, errors.NewSSHConnectionError(cfg.Host, err)
 	}
+	defer auth.Close()
 
-	signer, err := ssh.ParsePrivateKey(keyData)
+	knownHostsPath := cfg.KnownHostsPath
+	if knownHostsPath == "" {
+		knownHostsPath = DefaultKnownHostsPath()
+	}
+	hostKeys, err := newHostKeyChecker(cfg.HostKeyPolicy, knownHostsPath, cfg.HashKnownHosts)
 	if err != nil {
-		return nil, errors.NewSSHConnectionError(host, err)
+		return nil, errors.NewSSHConnectionError(cfg.Host, err)
 	}
 
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	hops := append(append([]JumpHost{}, cfg.ProxyJump...), JumpHost{User: cfg.User, Host: cfg.Host, Port: cfg.Port})
+
+	var clients []*ssh.Client
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
+		}
 	}
 
-	addr := fmt.Sprintf("%s:%d", host, port)
+	for _, hop := range hops {
+		addr := fmt.Sprintf("%s:%d", hop.Host, hop.Port)
+		config := &ssh.ClientConfig{
+			User:              hop.User,
+			Auth:              auth.Methods(),
+			HostKeyCallback:   hostKeys.Callback,
+			HostKeyAlgorithms: hostKeys.Algorithms(addr),
+		}
 
-	sshConn, err := ssh.Dial("tcp", addr, config)
-	if err != nil {
-		return nil, errors.NewSSHConnectionError(host, err)
+		var client *ssh.Client
+		if len(clients) == 0 {
+			client, err = ssh.Dial("tcp", addr, config)
+		} else {
+			client, err = dialThrough(clients[len(clients)-1], addr, config)
+		}
+		if err != nil {
+			closeAll()
+			return nil, errors.NewSSHConnectionError(hop.Host, err)
+		}
+		clients = append(clients, client)
 	}
 
+	sshConn := clients[len(clients)-1]
+	jumps := clients[:len(clients)-1]
+
 	sftpClient, err := sftp.NewClient(sshConn)
 	if err != nil {
-		sshConn.Close()
-		return nil, errors.NewSSHConnectionError(host, err)
+		closeAll()
+		return nil, errors.NewSSHConnectionError(cfg.Host, err)
 	}
 
 	return &SSHClient{
 		sshClient: sshConn,
+		jumps:     jumps,
 		sftp:      sftpClient,
 	}, nil
 }
 
-func (c *SSHClient) Upload(src, dst string) error {
-	if c == nil || c.sftp == nil {
-		return errors.NewSSHConnectionError("nil", fmt.Errorf("SSH клиент не инициализирован"))
+func dialThrough(jump *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := jump.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
 	}
 
-	srcFile, err := os.Open(src)
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
 	if err != nil {
-		return c.wrapSSHError("", src, dst, err)
+		conn.Close()
+		return nil, err
 	}
-	defer srcFile.Close()
 
-	if err := c.sftp.MkdirAll(filepath.Dir(dst)); err != nil {
-		return c.wrapSSHError("", src, dst, fmt.Errorf("failed to create remote directory: %w", err))
-	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
 
-	dstFile, err := c.sftp.Create(dst)
-	if err != nil {
-		return c.wrapSSHError("", src, dst, err)
+func (c *SSHClient) Upload(src, dst string) error {
+	if c == nil || c.sftp == nil {
+		return errors.NewSSHConnectionError("nil", fmt.Errorf("SSH клиент не инициализирован"))
 	}
-	defer dstFile.Close()
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+	srcFile, err := os.Open(src)
+	if err != nil {
 		return c.wrapSSHError("", src, dst, err)
 	}
+	defer srcFile.Close()
 
-	return nil
+	return c.writeRemote(srcFile, src, dst)
 }
 
 func (c *SSHClient) Download(src, dst string) error {
@@ -116,20 +163,85 @@ func (c *SSHClient) UploadReader(r io.Reader, dst string) error {
 		return errors.NewSSHConnectionError("nil", fmt.Errorf("SSH клиент не инициализирован"))
 	}
 
+	return c.writeRemote(r, "(in-memory)", dst)
+}
+
+// writeRemote записывает данные во временный файл рядом с dst и затем
+// переименовывает его, чтобы читатели никогда не видели частичную запись.
+func (c *SSHClient) writeRemote(r io.Reader, src, dst string) error {
+	if err := c.sftp.MkdirAll(filepath.Dir(dst)); err != nil {
+		return c.wrapSSHError("", src, dst, fmt.Errorf("failed to create remote directory: %w", err))
+	}
+
+	tmp := fmt.Sprintf("%s.%d.tmp", dst, time.Now().UnixNano())
+	dstFile, err := c.sftp.Create(tmp)
+	if err != nil {
+		return c.wrapSSHError("", src, dst, err)
+	}
+
+	if _, err := io.Copy(dstFile, r); err != nil {
+		dstFile.Close()
+		c.sftp.Remove(tmp)
+		return c.wrapSSHError("", src, dst, err)
+	}
+	if err := dstFile.Close(); err != nil {
+		c.sftp.Remove(tmp)
+		return c.wrapSSHError("", src, dst, err)
+	}
+
+	if err := c.sftp.PosixRename(tmp, dst); err != nil {
+		// Сервер без расширения posix-rename: обычный Rename не заменяет
+		// существующий файл, поэтому сначала удаляем его
+		c.sftp.Remove(dst)
+		if err := c.sftp.Rename(tmp, dst); err != nil {
+			c.sftp.Remove(tmp)
+			return c.wrapSSHError("", src, dst, err)
+		}
+	}
+
+	return nil
+}
+
+// CreateExclusive записывает dst, только если его ещё нет. Серверы SFTP
+// версии 3 не сообщают причину отказа при O_EXCL, поэтому существование
+// файла проверяется отдельно.
+func (c *SSHClient) CreateExclusive(r io.Reader, dst string) error {
+	if c == nil || c.sftp == nil {
+		return errors.NewSSHConnectionError("nil", fmt.Errorf("SSH клиент не инициализирован"))
+	}
+
 	if err := c.sftp.MkdirAll(filepath.Dir(dst)); err != nil {
 		return c.wrapSSHError("", "(in-memory)", dst, fmt.Errorf("failed to create remote directory: %w", err))
 	}
 
-	dstFile, err := c.sftp.Create(dst)
+	f, err := c.sftp.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
 	if err != nil {
+		if _, statErr := c.sftp.Stat(dst); statErr == nil {
+			err = os.ErrExist
+		}
 		return c.wrapSSHError("", "(in-memory)", dst, err)
 	}
-	defer dstFile.Close()
 
-	if _, err := io.Copy(dstFile, r); err != nil {
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		c.sftp.Remove(dst)
+		return c.wrapSSHError("", "(in-memory)", dst, err)
+	}
+	if err := f.Close(); err != nil {
+		c.sftp.Remove(dst)
 		return c.wrapSSHError("", "(in-memory)", dst, err)
 	}
+	return nil
+}
 
+func (c *SSHClient) Remove(path string) error {
+	if c == nil || c.sftp == nil {
+		return errors.NewSSHConnectionError("nil", fmt.Errorf("SSH клиент не инициализирован"))
+	}
+
+	if err := c.sftp.Remove(path); err != nil {
+		return c.wrapSSHError("", "", path, err)
+	}
 	return nil
 }
 
@@ -165,6 +277,12 @@ func (c *SSHClient) Close() error {
 		}
 	}
 
+	for i := len(c.jumps) - 1; i >= 0; i-- {
+		if err := c.jumps[i].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	if len(errs) > 0 {
 		return errs[0]
 	}
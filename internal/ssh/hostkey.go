@@ -0,0 +1,180 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"pm/internal/errors"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type HostKeyPolicy string
+
+const (
+	HostKeyStrict    HostKeyPolicy = "strict"
+	HostKeyAcceptNew HostKeyPolicy = "accept-new"
+	HostKeyOff       HostKeyPolicy = "off"
+)
+
+func ParseHostKeyPolicy(s string) (HostKeyPolicy, error) {
+	switch HostKeyPolicy(strings.ToLower(strings.TrimSpace(s))) {
+	case "", HostKeyStrict, "yes":
+		return HostKeyStrict, nil
+	case HostKeyAcceptNew:
+		return HostKeyAcceptNew, nil
+	case HostKeyOff, "no":
+		return HostKeyOff, nil
+	default:
+		return "", fmt.Errorf("неизвестный режим проверки ключа хоста: %q (допустимо: strict, accept-new, off)", s)
+	}
+}
+
+func DefaultKnownHostsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+type hostKeyChecker struct {
+	policy HostKeyPolicy
+	path   string
+	hash   bool
+	check  ssh.HostKeyCallback
+	mu     sync.Mutex
+}
+
+func newHostKeyChecker(policy HostKeyPolicy, path string, hash bool) (*hostKeyChecker, error) {
+	c := &hostKeyChecker{policy: policy, path: path, hash: hash}
+	if policy == HostKeyOff {
+		return c, nil
+	}
+	if path == "" {
+		return nil, fmt.Errorf("не удалось определить путь к known_hosts")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		// Отсутствующий файл равносилен пустому: все хосты считаются неизвестными
+		c.check = func(string, net.Addr, ssh.PublicKey) error {
+			return &knownhosts.KeyError{}
+		}
+		return c, nil
+	}
+
+	check, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения %s: %w", path, err)
+	}
+	c.check = check
+	return c, nil
+}
+
+func (c *hostKeyChecker) Callback(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if c.policy == HostKeyOff {
+		return nil
+	}
+
+	err := c.check(hostname, remote, key)
+	if err == nil {
+		return nil
+	}
+
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	var revoked *knownhosts.RevokedError
+	if stderrors.As(err, &revoked) {
+		return errors.NewHostKeyMismatchError(hostname, fingerprint, revoked.Revoked.Filename, revoked.Revoked.Line, err)
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !stderrors.As(err, &keyErr) {
+		return err
+	}
+
+	if len(keyErr.Want) > 0 {
+		want := keyErr.Want[0]
+		return errors.NewHostKeyMismatchError(hostname, fingerprint, want.Filename, want.Line, err)
+	}
+
+	if c.policy != HostKeyAcceptNew {
+		return errors.NewUnknownHostKeyError(hostname, fingerprint, c.path)
+	}
+
+	return c.add(hostname, key)
+}
+
+// Algorithms возвращает алгоритмы ключей, уже известных для хоста, чтобы сервер
+// предъявил именно их, а не другой тип ключа, который вызовет ложное несовпадение.
+func (c *hostKeyChecker) Algorithms(hostname string) []string {
+	if c.policy == HostKeyOff || c.check == nil {
+		return nil
+	}
+
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil
+	}
+	probe, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !stderrors.As(c.check(hostname, &net.TCPAddr{}, probe), &keyErr) {
+		return nil
+	}
+
+	var algos []string
+	seen := make(map[string]bool)
+	for _, known := range keyErr.Want {
+		for _, algo := range algorithmsForKeyType(known.Key.Type()) {
+			if !seen[algo] {
+				seen[algo] = true
+				algos = append(algos, algo)
+			}
+		}
+	}
+	return algos
+}
+
+func (c *hostKeyChecker) add(hostname string, key ssh.PublicKey) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	address := knownhosts.Normalize(hostname)
+	if c.hash {
+		address = knownhosts.HashHostname(address)
+	}
+
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{address}, key))
+	return err
+}
+
+func algorithmsForKeyType(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
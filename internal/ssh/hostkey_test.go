@@ -0,0 +1,170 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	stderrors "errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pm/internal/errors"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	const host = "repo.example.com:22"
+	addr := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+	known := newHostKey(t)
+	other := newHostKey(t)
+
+	tests := []struct {
+		name      string
+		policy    HostKeyPolicy
+		knownKey  ssh.PublicKey
+		noFile    bool
+		key       ssh.PublicKey
+		wantErr   interface{}
+		wantAdded bool
+	}{
+		{name: "strict: известный ключ", policy: HostKeyStrict, knownKey: known, key: known},
+		{name: "strict: неизвестный хост", policy: HostKeyStrict, key: other, wantErr: new(*errors.UnknownHostKeyError)},
+		{name: "strict: нет файла known_hosts", policy: HostKeyStrict, noFile: true, key: other, wantErr: new(*errors.UnknownHostKeyError)},
+		{name: "strict: ключ изменился", policy: HostKeyStrict, knownKey: known, key: other, wantErr: new(*errors.HostKeyMismatchError)},
+		{name: "accept-new: новый хост добавляется", policy: HostKeyAcceptNew, key: other, wantAdded: true},
+		{name: "accept-new: нет файла known_hosts", policy: HostKeyAcceptNew, noFile: true, key: other, wantAdded: true},
+		{name: "accept-new: ключ изменился", policy: HostKeyAcceptNew, knownKey: known, key: other, wantErr: new(*errors.HostKeyMismatchError)},
+		{name: "off: любой ключ", policy: HostKeyOff, knownKey: known, key: other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+			if !tt.noFile {
+				var content string
+				if tt.knownKey != nil {
+					content = knownhosts.Line([]string{knownhosts.Normalize(host)}, tt.knownKey) + "\n"
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c, err := newHostKeyChecker(tt.policy, path, false)
+			if err != nil {
+				t.Fatalf("Ошибка newHostKeyChecker: %v", err)
+			}
+
+			err = c.Callback(host, addr, tt.key)
+			if tt.wantErr != nil {
+				if err == nil || !stderrors.As(err, tt.wantErr) {
+					t.Fatalf("Ожидалась ошибка %T, получено: %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+
+			if !tt.wantAdded {
+				return
+			}
+			// Добавленный ключ должен приниматься уже в строгом режиме
+			strict, err := newHostKeyChecker(HostKeyStrict, path, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := strict.Callback(host, addr, tt.key); err != nil {
+				t.Errorf("Добавленный ключ не принят: %v", err)
+			}
+		})
+	}
+}
+
+func TestHostKeyMismatchError(t *testing.T) {
+	const host = "repo.example.com:2222"
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	known := newHostKey(t)
+	content := "# комментарий\n" + knownhosts.Line([]string{knownhosts.Normalize(host)}, known) + "\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := newHostKeyChecker(HostKeyAcceptNew, path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newHostKey(t)
+	err = c.Callback(host, &net.TCPAddr{}, other)
+
+	var mismatch *errors.HostKeyMismatchError
+	if !stderrors.As(err, &mismatch) {
+		t.Fatalf("Ожидалась ошибка HostKeyMismatchError, получено: %v", err)
+	}
+	for _, want := range []string{ssh.FingerprintSHA256(other), path + ":2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Сообщение %q не содержит %q", err.Error(), want)
+		}
+	}
+
+	// При несовпадении файл не должен меняться
+	data, _ := os.ReadFile(path)
+	if string(data) != content {
+		t.Errorf("known_hosts изменён при несовпадении ключа:\n%s", data)
+	}
+}
+
+func TestHostKeyHashed(t *testing.T) {
+	const host = "repo.example.com:22"
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	key := newHostKey(t)
+
+	c, err := newHostKeyChecker(HostKeyAcceptNew, path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Callback(host, &net.TCPAddr{}, key); err != nil {
+		t.Fatalf("Ошибка добавления ключа: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "|1|") {
+		t.Errorf("Ожидалась хешированная запись, получено: %s", line)
+	}
+	if strings.Contains(line, "repo.example.com") {
+		t.Errorf("Имя хоста записано в открытом виде: %s", line)
+	}
+
+	strict, err := newHostKeyChecker(HostKeyStrict, path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := strict.Callback(host, &net.TCPAddr{}, key); err != nil {
+		t.Errorf("Хешированная запись не распознана: %v", err)
+	}
+	if algos := strict.Algorithms(host); len(algos) != 1 || algos[0] != ssh.KeyAlgoED25519 {
+		t.Errorf("Algorithms() = %v, ожидалось [%s]", algos, ssh.KeyAlgoED25519)
+	}
+}
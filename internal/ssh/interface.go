@@ -9,6 +9,8 @@ type ClientInterface interface {
 	Upload(src, dst string) error
 	Download(src, dst string) error
 	UploadReader(r io.Reader, dst string) error
+	CreateExclusive(r io.Reader, dst string) error
+	Remove(path string) error
 	ReadDir(path string) ([]os.FileInfo, error)
 	Close() error
 }
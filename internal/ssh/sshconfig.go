@@ -0,0 +1,279 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type JumpHost struct {
+	User string
+	Host string
+	Port int
+}
+
+func (j JumpHost) String() string {
+	return fmt.Sprintf("%s@%s:%d", j.User, j.Host, j.Port)
+}
+
+type hostEntry struct {
+	patterns []string
+	options  map[string][]string
+}
+
+type SSHConfigFile struct {
+	entries []hostEntry
+}
+
+func DefaultSSHConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "config")
+}
+
+func LoadSSHConfig(path string) (*SSHConfigFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &SSHConfigFile{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	// Параметры до первого Host относятся ко всем хостам
+	cfg := &SSHConfigFile{
+		entries: []hostEntry{{patterns: []string{"*"}, options: map[string][]string{}}},
+	}
+	current := &cfg.entries[0]
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value := splitConfigLine(line)
+		if key == "" {
+			return nil, fmt.Errorf("%s:%d: некорректная строка", path, lineNum)
+		}
+
+		switch key {
+		case "host":
+			cfg.entries = append(cfg.entries, hostEntry{
+				patterns: strings.Fields(value),
+				options:  map[string][]string{},
+			})
+			current = &cfg.entries[len(cfg.entries)-1]
+		case "match":
+			// Блоки Match не поддерживаются: их параметры пропускаются
+			cfg.entries = append(cfg.entries, hostEntry{options: map[string][]string{}})
+			current = &cfg.entries[len(cfg.entries)-1]
+		default:
+			current.options[key] = append(current.options[key], value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func splitConfigLine(line string) (string, string) {
+	idx := strings.IndexAny(line, " \t=")
+	if idx < 0 {
+		return strings.ToLower(line), ""
+	}
+	key := strings.ToLower(line[:idx])
+	value := strings.TrimLeft(line[idx:], " \t")
+	value = strings.TrimPrefix(value, "=")
+	value = strings.TrimSpace(value)
+	value = strings.Trim(value, `"`)
+	return key, value
+}
+
+// Get возвращает первое найденное значение параметра для хоста,
+// как это делает OpenSSH.
+func (c *SSHConfigFile) Get(alias, key string) string {
+	values := c.GetAll(alias, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c *SSHConfigFile) GetAll(alias, key string) []string {
+	key = strings.ToLower(key)
+	var values []string
+	for _, entry := range c.entries {
+		if !matchHostPatterns(entry.patterns, alias) {
+			continue
+		}
+		values = append(values, entry.options[key]...)
+	}
+	return values
+}
+
+func matchHostPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, p := range patterns {
+		negate := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		ok, err := path.Match(p, host)
+		if err != nil || !ok {
+			continue
+		}
+		if negate {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// Resolve дополняет незаданные поля cfg значениями из ssh_config для
+// псевдонима cfg.Host. Уже заполненные поля не перезаписываются.
+func (c *SSHConfigFile) Resolve(cfg Config) (Config, error) {
+	alias := cfg.Host
+	if alias == "" {
+		return cfg, nil
+	}
+
+	if hostName := c.Get(alias, "HostName"); hostName != "" {
+		cfg.Host = strings.ReplaceAll(hostName, "%h", alias)
+	}
+
+	if cfg.User == "" {
+		cfg.User = c.Get(alias, "User")
+	}
+
+	if cfg.Port == 0 {
+		if p := c.Get(alias, "Port"); p != "" {
+			port, err := strconv.Atoi(p)
+			if err != nil {
+				return cfg, fmt.Errorf("некорректный Port %q для хоста %s", p, alias)
+			}
+			cfg.Port = port
+		}
+	}
+
+	if cfg.KeyPath == "" {
+		for _, identity := range c.GetAll(alias, "IdentityFile") {
+			identity = expandPath(identity, alias, cfg)
+			if _, err := os.Stat(identity); err == nil {
+				cfg.KeyPath = identity
+				break
+			}
+		}
+	}
+
+	if cfg.CertPath == "" {
+		if cert := c.Get(alias, "CertificateFile"); cert != "" {
+			cfg.CertPath = expandPath(cert, alias, cfg)
+		}
+	}
+
+	if cfg.KnownHostsPath == "" {
+		if known := c.Get(alias, "UserKnownHostsFile"); known != "" {
+			cfg.KnownHostsPath = expandPath(strings.Fields(known)[0], alias, cfg)
+		}
+	}
+
+	if len(cfg.ProxyJump) == 0 {
+		jumps, err := c.parseProxyJump(c.Get(alias, "ProxyJump"), cfg.User)
+		if err != nil {
+			return cfg, fmt.Errorf("некорректный ProxyJump для хоста %s: %w", alias, err)
+		}
+		cfg.ProxyJump = jumps
+	}
+
+	if cfg.Port == 0 {
+		cfg.Port = 22
+	}
+
+	return cfg, nil
+}
+
+func (c *SSHConfigFile) parseProxyJump(value, defaultUser string) ([]JumpHost, error) {
+	if value == "" || strings.EqualFold(value, "none") {
+		return nil, nil
+	}
+
+	var jumps []JumpHost
+	for _, hop := range strings.Split(value, ",") {
+		hop = strings.TrimSpace(hop)
+		hop = strings.TrimPrefix(hop, "ssh://")
+
+		jump := JumpHost{}
+		if at := strings.LastIndex(hop, "@"); at >= 0 {
+			jump.User = hop[:at]
+			hop = hop[at+1:]
+		}
+		if colon := strings.LastIndex(hop, ":"); colon >= 0 && !strings.HasSuffix(hop, "]") {
+			port, err := strconv.Atoi(hop[colon+1:])
+			if err != nil {
+				return nil, fmt.Errorf("некорректный порт в %q", hop)
+			}
+			jump.Port = port
+			hop = hop[:colon]
+		}
+		hop = strings.Trim(hop, "[]")
+		if hop == "" {
+			return nil, fmt.Errorf("пустой адрес промежуточного хоста")
+		}
+
+		// Промежуточный хост тоже может быть псевдонимом из ssh_config
+		jump.Host = hop
+		if hostName := c.Get(hop, "HostName"); hostName != "" {
+			jump.Host = strings.ReplaceAll(hostName, "%h", hop)
+		}
+		if jump.User == "" {
+			jump.User = c.Get(hop, "User")
+		}
+		if jump.User == "" {
+			jump.User = defaultUser
+		}
+		if jump.Port == 0 {
+			if p := c.Get(hop, "Port"); p != "" {
+				port, err := strconv.Atoi(p)
+				if err != nil {
+					return nil, fmt.Errorf("некорректный Port %q для хоста %s", p, hop)
+				}
+				jump.Port = port
+			}
+		}
+		if jump.Port == 0 {
+			jump.Port = 22
+		}
+
+		jumps = append(jumps, jump)
+	}
+
+	return jumps, nil
+}
+
+func expandPath(p, alias string, cfg Config) string {
+	home, _ := os.UserHomeDir()
+	if strings.HasPrefix(p, "~/") {
+		p = filepath.Join(home, p[2:])
+	}
+	replacer := strings.NewReplacer(
+		"%d", home,
+		"%h", cfg.Host,
+		"%n", alias,
+		"%r", cfg.User,
+		"%u", os.Getenv("USER"),
+		"%%", "%",
+	)
+	return replacer.Replace(p)
+}
@@ -0,0 +1,227 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testSSHConfig = `# Общие параметры
+User default
+
+Host repo
+    HostName repo.example.com
+    Port 2222
+    User deploy
+    ProxyJump bastion
+
+Host bastion
+    HostName=bastion.example.com
+    User "jump"
+
+Host *.internal !db.internal
+    User internal
+
+Host multi
+    HostName %h.example.com
+    ProxyJump admin@first:2200,second,[::1]:2022
+
+Match host other
+    User ignored
+`
+
+func loadTestConfig(t *testing.T, content string) *SSHConfigFile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadSSHConfig(path)
+	if err != nil {
+		t.Fatalf("Ошибка LoadSSHConfig: %v", err)
+	}
+	return cfg
+}
+
+func TestSSHConfigGet(t *testing.T) {
+	cfg := loadTestConfig(t, testSSHConfig)
+
+	tests := []struct {
+		name  string
+		alias string
+		key   string
+		want  string
+	}{
+		{name: "параметр до первого Host", alias: "anything", key: "User", want: "default"},
+		{name: "первое значение побеждает", alias: "repo", key: "User", want: "default"},
+		{name: "параметр блока Host", alias: "repo", key: "HostName", want: "repo.example.com"},
+		{name: "ключ без учёта регистра", alias: "repo", key: "hostname", want: "repo.example.com"},
+		{name: "разделитель =", alias: "bastion", key: "HostName", want: "bastion.example.com"},
+		{name: "значение в кавычках", alias: "bastion", key: "User", want: "default"},
+		{name: "неизвестный хост", alias: "unknown", key: "HostName", want: ""},
+		{name: "блок Match пропускается", alias: "other", key: "User", want: "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.Get(tt.alias, tt.key); got != tt.want {
+				t.Errorf("Get(%q, %q) = %q, ожидалось %q", tt.alias, tt.key, got, tt.want)
+			}
+		})
+	}
+
+	if got := cfg.GetAll("bastion", "User"); !reflect.DeepEqual(got, []string{"default", "jump"}) {
+		t.Errorf("GetAll(bastion, User) = %v", got)
+	}
+}
+
+func TestLoadSSHConfigMissing(t *testing.T) {
+	cfg, err := LoadSSHConfig(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("Отсутствующий файл не должен быть ошибкой: %v", err)
+	}
+	if got := cfg.Get("repo", "HostName"); got != "" {
+		t.Errorf("Get() = %q для пустой конфигурации", got)
+	}
+}
+
+func TestMatchHostPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{name: "точное совпадение", patterns: []string{"repo"}, host: "repo", want: true},
+		{name: "нет совпадения", patterns: []string{"repo"}, host: "repo2", want: false},
+		{name: "звёздочка", patterns: []string{"*"}, host: "repo", want: true},
+		{name: "маска домена", patterns: []string{"*.internal"}, host: "app.internal", want: true},
+		{name: "вопросительный знак", patterns: []string{"web?"}, host: "web1", want: true},
+		{name: "вопросительный знак и длина", patterns: []string{"web?"}, host: "web10", want: false},
+		{name: "один из нескольких", patterns: []string{"a", "b"}, host: "b", want: true},
+		{name: "исключение", patterns: []string{"*.internal", "!db.internal"}, host: "db.internal", want: false},
+		{name: "исключение раньше маски", patterns: []string{"!db.internal", "*.internal"}, host: "db.internal", want: false},
+		{name: "только исключение", patterns: []string{"!db"}, host: "web", want: false},
+		{name: "нет шаблонов", patterns: nil, host: "repo", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchHostPatterns(tt.patterns, tt.host); got != tt.want {
+				t.Errorf("matchHostPatterns(%v, %q) = %v, ожидалось %v", tt.patterns, tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProxyJump(t *testing.T) {
+	cfg := loadTestConfig(t, testSSHConfig)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    []JumpHost
+		wantErr bool
+	}{
+		{name: "пусто", value: "", want: nil},
+		{name: "none", value: "none", want: nil},
+		{name: "только хост", value: "gw", want: []JumpHost{{User: "default", Host: "gw", Port: 22}}},
+		{name: "пользователь, хост и порт", value: "admin@gw:2200", want: []JumpHost{{User: "admin", Host: "gw", Port: 2200}}},
+		{name: "схема ssh://", value: "ssh://admin@gw:2200", want: []JumpHost{{User: "admin", Host: "gw", Port: 2200}}},
+		{name: "IPv6 с портом", value: "[::1]:2022", want: []JumpHost{{User: "default", Host: "::1", Port: 2022}}},
+		{
+			name:  "псевдоним из ssh_config",
+			value: "bastion",
+			want:  []JumpHost{{User: "default", Host: "bastion.example.com", Port: 22}},
+		},
+		{
+			name:  "несколько промежуточных хостов",
+			value: "admin@first:2200, second,repo",
+			want: []JumpHost{
+				{User: "admin", Host: "first", Port: 2200},
+				{User: "default", Host: "second", Port: 22},
+				{User: "default", Host: "repo.example.com", Port: 2222},
+			},
+		},
+		{name: "некорректный порт", value: "gw:ssh", wantErr: true},
+		{name: "пустой хост", value: "gw,,other", wantErr: true},
+		{name: "только пользователь", value: "admin@", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cfg.parseProxyJump(tt.value, "default")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Ожидалась ошибка, получено %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProxyJump(%q) = %v, ожидалось %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSHConfigResolve(t *testing.T) {
+	cfg := loadTestConfig(t, testSSHConfig)
+
+	tests := []struct {
+		name string
+		in   Config
+		want Config
+	}{
+		{
+			name: "псевдоним с ProxyJump",
+			in:   Config{Host: "repo"},
+			want: Config{
+				Host: "repo.example.com", User: "default", Port: 2222,
+				ProxyJump: []JumpHost{{User: "default", Host: "bastion.example.com", Port: 22}},
+			},
+		},
+		{
+			name: "явные значения не перезаписываются",
+			in:   Config{Host: "repo", User: "ci", Port: 22, ProxyJump: []JumpHost{{User: "ci", Host: "gw", Port: 22}}},
+			want: Config{Host: "repo.example.com", User: "ci", Port: 22, ProxyJump: []JumpHost{{User: "ci", Host: "gw", Port: 22}}},
+		},
+		{
+			name: "подстановка %h и цепочка хостов со своими User",
+			in:   Config{Host: "multi", User: "me"},
+			want: Config{
+				Host: "multi.example.com", User: "me", Port: 22,
+				ProxyJump: []JumpHost{
+					{User: "admin", Host: "first", Port: 2200},
+					{User: "default", Host: "second", Port: 22},
+					{User: "default", Host: "::1", Port: 2022},
+				},
+			},
+		},
+		{
+			name: "хост без настроек",
+			in:   Config{Host: "plain.example.com"},
+			want: Config{Host: "plain.example.com", User: "default", Port: 22},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cfg.Resolve(tt.in)
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+
+	bad := loadTestConfig(t, "Host repo\n    Port ssh\n")
+	if _, err := bad.Resolve(Config{Host: "repo"}); err == nil {
+		t.Error("Ожидалась ошибка для некорректного Port")
+	}
+}
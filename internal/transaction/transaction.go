@@ -0,0 +1,249 @@
+package transaction
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// change — изменение в корне установки, которое можно отменить. Если
+// backup не пуст, прежнее содержимое path перемещено туда.
+type change struct {
+	path   string
+	backup string
+	dir    bool
+}
+
+// Transaction применяет распакованные пакеты к корню установки так, чтобы
+// при ошибке любого из них все изменения можно было отменить.
+//
+// Пакеты распаковываются во временные директории внутри <root>/.pm/tmp —
+// на той же файловой системе, что и root, поэтому каждый файл переносится
+// на место одним атомарным rename. Заменяемые и удаляемые файлы не
+// стираются, а переносятся в резервную директорию транзакции до Commit.
+type Transaction struct {
+	root string
+	// meta — служебная директория pm относительно root; пакеты не могут
+	// записывать в неё файлы.
+	meta string
+	dir  string
+
+	mu      sync.Mutex
+	staged  int
+	backups int
+	changes []change
+}
+
+// Begin начинает транзакцию для корня root; служебные файлы хранятся в
+// metaDir (относительно root).
+func Begin(root, metaDir string) (*Transaction, error) {
+	tmp := filepath.Join(root, metaDir, "tmp")
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(tmp, "tx-")
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range []string{"stage", "backup"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	return &Transaction{root: root, meta: filepath.Clean(metaDir), dir: dir}, nil
+}
+
+// Dir возвращает служебную директорию транзакции.
+func (t *Transaction) Dir() string {
+	return t.dir
+}
+
+// StageDir создаёт пустую директорию для распаковки пакета name.
+// Безопасна для параллельного вызова.
+func (t *Transaction) StageDir(name string) (string, error) {
+	t.mu.Lock()
+	t.staged++
+	n := t.staged
+	t.mu.Unlock()
+
+	dir := filepath.Join(t.dir, "stage", strconv.Itoa(n)+"-"+filepath.Base(name))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Apply переносит содержимое распакованной директории stage в корень.
+// Существующие файлы переносятся в резервную директорию; директория на
+// месте файла (или наоборот) заменяется целиком. Символическая ссылка на
+// месте директории сохраняется: если она ведёт внутрь корня, файлы пакета
+// записываются через неё, иначе Apply завершается ошибкой.
+func (t *Transaction) Apply(stage string) error {
+	return filepath.WalkDir(stage, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(stage, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if rel == t.meta || strings.HasPrefix(rel, t.meta+string(filepath.Separator)) {
+			return fmt.Errorf("%s: служебная директория %s не может быть изменена пакетом", rel, t.meta)
+		}
+		target := filepath.Join(t.root, rel)
+
+		if d.IsDir() {
+			info, err := os.Lstat(target)
+			if err == nil && info.IsDir() {
+				return nil
+			}
+			if err == nil && info.Mode()&os.ModeSymlink != 0 {
+				if linked, err := t.linkedDir(target); err != nil || linked {
+					if err != nil {
+						return fmt.Errorf("%s: %w", rel, err)
+					}
+					return nil
+				}
+			}
+			backup, err := t.backup(target)
+			if err != nil {
+				return err
+			}
+			if err := os.Mkdir(target, 0755); err != nil {
+				t.restore(target, backup)
+				return err
+			}
+			t.record(change{path: target, backup: backup, dir: true})
+			return nil
+		}
+
+		if info, err := os.Lstat(target); err == nil && info.IsDir() {
+			return fmt.Errorf("%s: директория не может быть заменена файлом", rel)
+		}
+		backup, err := t.backup(target)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(path, target); err != nil {
+			t.restore(target, backup)
+			return err
+		}
+		t.record(change{path: target, backup: backup})
+		return nil
+	})
+}
+
+// Remove удаляет файл path из корня с возможностью отмены.
+func (t *Transaction) Remove(path string) error {
+	backup, err := t.backup(path)
+	if err != nil {
+		return err
+	}
+	if backup == "" {
+		return os.ErrNotExist
+	}
+	t.record(change{path: path, backup: backup})
+	return nil
+}
+
+// Rollback отменяет все изменения в обратном порядке и удаляет служебную
+// директорию. Возвращает первую ошибку; при ошибке резервные копии
+// остаются в Dir().
+func (t *Transaction) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var first error
+	for i := len(t.changes) - 1; i >= 0; i-- {
+		c := t.changes[i]
+		if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) && first == nil {
+			first = fmt.Errorf("%s: %w", c.path, err)
+		}
+		if c.backup == "" {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil && first == nil {
+			first = err
+		}
+		if err := os.Rename(c.backup, c.path); err != nil && first == nil {
+			first = fmt.Errorf("%s: %w", c.path, err)
+		}
+	}
+	t.changes = nil
+
+	if first != nil {
+		return first
+	}
+	return os.RemoveAll(t.dir)
+}
+
+// Commit завершает транзакцию, удаляя резервные копии.
+func (t *Transaction) Commit() error {
+	t.mu.Lock()
+	t.changes = nil
+	t.mu.Unlock()
+	return os.RemoveAll(t.dir)
+}
+
+// linkedDir сообщает, ведёт ли символическая ссылка path на директорию
+// внутри корня. Ссылка на директорию за пределами корня — ошибка: заменить
+// её значит молча удалить настройку пользователя, а пройти по ней — записать
+// файлы пакета вне корня.
+func (t *Transaction) linkedDir(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		// битая ссылка или ссылка на файл заменяется как обычный файл
+		return false, nil
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false, err
+	}
+	root, err := filepath.EvalSymlinks(t.root)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false, fmt.Errorf("символическая ссылка ведёт за пределы корня установки (%s)", resolved)
+	}
+	return true, nil
+}
+
+// backup переносит существующий path в резервную директорию и возвращает
+// новый путь; пустая строка — path не существовал.
+func (t *Transaction) backup(path string) (string, error) {
+	if _, err := os.Lstat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	t.mu.Lock()
+	t.backups++
+	backup := filepath.Join(t.dir, "backup", strconv.Itoa(t.backups))
+	t.mu.Unlock()
+	if err := os.Rename(path, backup); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+func (t *Transaction) restore(path, backup string) {
+	if backup != "" {
+		os.Rename(backup, path)
+	}
+}
+
+func (t *Transaction) record(c change) {
+	t.mu.Lock()
+	t.changes = append(t.changes, c)
+	t.mu.Unlock()
+}
@@ -0,0 +1,191 @@
+package transaction
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Ошибка чтения %s: %v", path, err)
+	}
+	return string(data)
+}
+
+// begin создаёт корень с файлами прежней установки и транзакцию, в которой
+// распакованы два пакета.
+func begin(t *testing.T) (string, *Transaction) {
+	t.Helper()
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "bin", "app"), "app 1.0")
+	writeFile(t, filepath.Join(root, "old.txt"), "old")
+
+	tx, err := Begin(root, ".pm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, files := range map[string]map[string]string{
+		"app": {"bin/app": "app 2.0", "share/app/readme": "readme"},
+		"lib": {"lib/lib.so": "lib"},
+	} {
+		stage, err := tx.StageDir(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for path, content := range files {
+			writeFile(t, filepath.Join(stage, filepath.FromSlash(path)), content)
+		}
+		if err := tx.Apply(stage); err != nil {
+			t.Fatalf("Ошибка Apply(%s): %v", name, err)
+		}
+	}
+	if err := tx.Remove(filepath.Join(root, "old.txt")); err != nil {
+		t.Fatal(err)
+	}
+	return root, tx
+}
+
+func TestCommit(t *testing.T) {
+	root, tx := begin(t)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, filepath.Join(root, "bin", "app")); got != "app 2.0" {
+		t.Errorf("bin/app = %q, ожидалась новая версия", got)
+	}
+	readFile(t, filepath.Join(root, "share", "app", "readme"))
+	readFile(t, filepath.Join(root, "lib", "lib.so"))
+	if _, err := os.Stat(filepath.Join(root, "old.txt")); !os.IsNotExist(err) {
+		t.Error("Удалённый файл остался на месте")
+	}
+	if _, err := os.Stat(tx.Dir()); !os.IsNotExist(err) {
+		t.Error("Служебная директория транзакции не удалена")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	root, tx := begin(t)
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, filepath.Join(root, "bin", "app")); got != "app 1.0" {
+		t.Errorf("bin/app = %q, ожидалась прежняя версия", got)
+	}
+	if got := readFile(t, filepath.Join(root, "old.txt")); got != "old" {
+		t.Errorf("old.txt = %q, ожидалось восстановление", got)
+	}
+	for _, path := range []string{"share", "lib"} {
+		if _, err := os.Stat(filepath.Join(root, path)); !os.IsNotExist(err) {
+			t.Errorf("Созданная транзакцией директория %s не удалена", path)
+		}
+	}
+	if _, err := os.Stat(tx.Dir()); !os.IsNotExist(err) {
+		t.Error("Служебная директория транзакции не удалена")
+	}
+}
+
+func TestApplySymlinkOutsideRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	// символическая ссылка на месте директории пакета не должна ни уводить
+	// файлы за пределы корня, ни молча заменяться директорией
+	if err := os.Symlink(outside, filepath.Join(root, "data")); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := Begin(root, ".pm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage, err := tx.StageDir("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(stage, "data", "file"), "content")
+	if err := tx.Apply(stage); err == nil {
+		t.Fatal("Ожидалась ошибка для ссылки за пределы корня")
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+		t.Error("Файл записан через символическую ссылку за пределы корня")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(root, "data")); err != nil || target != outside {
+		t.Errorf("Символическая ссылка не сохранена: %q, %v", target, err)
+	}
+}
+
+func TestApplySymlinkInsideRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "data", "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// пользователь перенёс lib в data/lib и оставил ссылку
+	if err := os.Symlink(filepath.Join("data", "lib"), filepath.Join(root, "lib")); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := Begin(root, ".pm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage, err := tx.StageDir("lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(stage, "lib", "lib.so"), "lib")
+	if err := tx.Apply(stage); err != nil {
+		t.Fatalf("Ошибка Apply: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if target, err := os.Readlink(filepath.Join(root, "lib")); err != nil || target != filepath.Join("data", "lib") {
+		t.Errorf("Символическая ссылка заменена: %q, %v", target, err)
+	}
+	if got := readFile(t, filepath.Join(root, "data", "lib", "lib.so")); got != "lib" {
+		t.Errorf("data/lib/lib.so = %q", got)
+	}
+}
+
+func TestApplyMetaDir(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".pm", "installed.json"), "база")
+
+	tx, err := Begin(root, ".pm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage, err := tx.StageDir("evil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(stage, ".pm", "installed.json"), "подмена")
+	if err := tx.Apply(stage); err == nil {
+		t.Fatal("Ожидалась ошибка для файла в служебной директории")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(root, ".pm", "installed.json")); got != "база" {
+		t.Errorf("База установленных пакетов перезаписана: %q", got)
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import "strings"
+
+var archiveExtensions = []struct {
+	ext    string
+	format string
+}{
+	{".zip", "zip"},
+	{".tar.gz", "tar.gz"},
+	{".tgz", "tar.gz"},
+}
+
+func ArchiveFormat(filename string) (format, extension string) {
+	for _, a := range archiveExtensions {
+		if strings.HasSuffix(filename, a.ext) {
+			return a.format, a.ext
+		}
+	}
+	return "", ""
+}
+
+func ParseArchiveName(filename string) (name, version, format string, ok bool) {
+	format, extension := ArchiveFormat(filename)
+	if format == "" {
+		return "", "", "", false
+	}
+
+	baseName := strings.TrimSuffix(filename, extension)
+	version = ExtractVersion(baseName)
+	if version == "" {
+		return "", "", "", false
+	}
+
+	name = strings.TrimSuffix(baseName, version)
+	if strings.HasSuffix(name, "-v") || strings.HasSuffix(name, "_v") {
+		name = strings.TrimSuffix(name, "v")
+	}
+	name = strings.TrimRight(name, "-_")
+	if name == "" {
+		return "", "", "", false
+	}
+
+	return name, version, format, true
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func FileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+const ChecksumExtension = ".sha256"
+
+// FormatChecksumFile возвращает содержимое файла контрольной суммы
+// в формате sha256sum, чтобы его можно было проверить и без pm.
+func FormatChecksumFile(checksum, filename string) string {
+	return checksum + "  " + filename + "\n"
+}
+
+func ParseChecksumFile(data []byte) (string, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("пустой файл контрольной суммы")
+	}
+
+	checksum := strings.ToLower(fields[0])
+	if len(checksum) != sha256.Size*2 {
+		return "", fmt.Errorf("некорректная контрольная сумма SHA-256: %q", fields[0])
+	}
+	if _, err := hex.DecodeString(checksum); err != nil {
+		return "", fmt.Errorf("некорректная контрольная сумма SHA-256: %q", fields[0])
+	}
+
+	return checksum, nil
+}
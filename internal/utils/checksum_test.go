@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testChecksum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func TestFileSHA256(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, size, err := FileSHA256(path)
+	if err != nil {
+		t.Fatalf("Ошибка FileSHA256: %v", err)
+	}
+	if checksum != testChecksum || size != 5 {
+		t.Errorf("FileSHA256() = %s, %d", checksum, size)
+	}
+
+	if _, _, err := FileSHA256(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Ожидалась ошибка для отсутствующего файла")
+	}
+}
+
+func TestParseChecksumFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{name: "формат sha256sum", data: FormatChecksumFile(testChecksum, "app-1.0.zip"), want: testChecksum},
+		{name: "только сумма", data: testChecksum, want: testChecksum},
+		{name: "двоичный режим sha256sum", data: testChecksum + " *app-1.0.zip\n", want: testChecksum},
+		{name: "верхний регистр и пробелы", data: "  " + strings.ToUpper(testChecksum) + "\tapp-1.0.zip\r\n", want: testChecksum},
+		{name: "пустой файл", data: "", wantErr: true},
+		{name: "только пробелы", data: " \n\t", wantErr: true},
+		{name: "короткая сумма", data: testChecksum[:63] + "  app-1.0.zip", wantErr: true},
+		{name: "длинная сумма", data: testChecksum + "0  app-1.0.zip", wantErr: true},
+		{name: "не шестнадцатеричная сумма", data: "z" + testChecksum[1:] + "  app-1.0.zip", wantErr: true},
+		{name: "сумма MD5", data: "5d41402abc4b2a76b9719d911017c592  app-1.0.zip", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChecksumFile([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Ожидалась ошибка, получено %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseChecksumFile() = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatChecksumFile(t *testing.T) {
+	got := FormatChecksumFile(testChecksum, "app-1.0.zip")
+	if want := testChecksum + "  app-1.0.zip\n"; got != want {
+		t.Errorf("FormatChecksumFile() = %q, ожидалось %q", got, want)
+	}
+
+	parsed, err := ParseChecksumFile([]byte(got))
+	if err != nil || parsed != testChecksum {
+		t.Errorf("Разбор результата FormatChecksumFile: %q, %v", parsed, err)
+	}
+}
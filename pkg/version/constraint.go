@@ -3,6 +3,7 @@ package version
 
 import (
 	"regexp"
+	"sort"
 	"strings"
 
 	"pm/internal/errors"
@@ -31,6 +32,14 @@ func Matches(versionStr, constraintStr string) (bool, error) {
 	return constraint.Check(v), nil
 }
 
+func Parse(s string) (*semver.Version, error) {
+	v, err := semver.NewVersion(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.NewVersionError(s, "", err)
+	}
+	return v, nil
+}
+
 func ParseConstraint(s string) (*semver.Constraints, error) {
 	s = normalize(s)
 
@@ -62,3 +71,77 @@ func normalize(s string) string {
 
 	return s
 }
+
+type Strategy string
+
+const (
+	Highest Strategy = "highest"
+	Lowest  Strategy = "lowest"
+)
+
+// Select возвращает индекс версии из versions, которая удовлетворяет
+// ограничению и является наибольшей (или наименьшей для Lowest).
+// Если подходящих версий нет, возвращается -1. Версии, которые не удаётся
+// разобрать как semver, пропускаются.
+func Select(versions []string, constraintStr string, strategy Strategy) (int, error) {
+	var constraint *semver.Constraints
+	if strings.TrimSpace(constraintStr) != "" {
+		c, err := ParseConstraint(constraintStr)
+		if err != nil {
+			return -1, errors.NewVersionError("", constraintStr, err)
+		}
+		constraint = c
+	}
+
+	best := -1
+	var bestVersion *semver.Version
+	for i, s := range versions {
+		v, err := semver.NewVersion(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+		if constraint != nil && !constraint.Check(v) {
+			continue
+		}
+
+		if bestVersion == nil ||
+			(strategy == Lowest && v.LessThan(bestVersion)) ||
+			(strategy != Lowest && v.GreaterThan(bestVersion)) {
+			best = i
+			bestVersion = v
+		}
+	}
+
+	return best, nil
+}
+
+// Sort возвращает индексы разбираемых версий в порядке предпочтения:
+// по убыванию для Highest и по возрастанию для Lowest.
+func Sort(versions []string, strategy Strategy) []int {
+	type parsed struct {
+		index   int
+		version *semver.Version
+	}
+
+	var items []parsed
+	for i, s := range versions {
+		v, err := semver.NewVersion(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+		items = append(items, parsed{index: i, version: v})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if strategy == Lowest {
+			return items[i].version.LessThan(items[j].version)
+		}
+		return items[i].version.GreaterThan(items[j].version)
+	})
+
+	indices := make([]int, len(items))
+	for i, item := range items {
+		indices[i] = item.index
+	}
+	return indices
+}
@@ -0,0 +1,90 @@
+package version
+
+import "testing"
+
+func TestSelect(t *testing.T) {
+	versions := []string{"1.0", "1.7", "not-a-version", "1.2", "2.0.0"}
+
+	tests := []struct {
+		name       string
+		constraint string
+		strategy   Strategy
+		want       int
+		wantErr    bool
+	}{
+		{name: "наибольшая без условия", strategy: Highest, want: 4},
+		{name: "наибольшая подходящая", constraint: "<2.0", strategy: Highest, want: 1},
+		{name: "наименьшая подходящая", constraint: ">1.0", strategy: Lowest, want: 3},
+		{name: "точная версия без оператора", constraint: "1.2", strategy: Highest, want: 3},
+		{name: "нет подходящей", constraint: ">=3.0", strategy: Highest, want: -1},
+		{name: "некорректное условие", constraint: ">>1.0", strategy: Highest, want: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Select(versions, tt.constraint, tt.strategy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ошибка = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Select(%q, %s) = %d, ожидалось %d", tt.constraint, tt.strategy, got, tt.want)
+			}
+		})
+	}
+
+	if got, _ := Select([]string{"bad", "worse"}, "", Highest); got != -1 {
+		t.Errorf("Неразбираемые версии должны пропускаться, получено %d", got)
+	}
+}
+
+func TestSort(t *testing.T) {
+	versions := []string{"1.2", "bad", "2.0.0", "1.0", "1.10"}
+
+	tests := []struct {
+		name     string
+		strategy Strategy
+		want     []int
+	}{
+		{name: "по убыванию", strategy: Highest, want: []int{2, 4, 0, 3}},
+		{name: "по возрастанию", strategy: Lowest, want: []int{3, 0, 4, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sort(versions, tt.strategy)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Sort = %v, ожидалось %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Sort = %v, ожидалось %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+		wantErr    bool
+	}{
+		{version: "1.5", constraint: ">=1.0, <2.0", want: true},
+		{version: "2.0", constraint: "<2.0", want: false},
+		{version: "1.0", constraint: "", want: true},
+		{version: "x", constraint: ">=1.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Matches(tt.version, tt.constraint)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Matches(%s, %q): ошибка = %v", tt.version, tt.constraint, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Matches(%s, %q) = %v, ожидалось %v", tt.version, tt.constraint, got, tt.want)
+		}
+	}
+}